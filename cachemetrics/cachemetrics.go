@@ -0,0 +1,128 @@
+// Package cachemetrics instruments caches with a Cache strategy.
+//
+// Wrap puts a Store, any cache with Get, Set and Delete, behind a Cache that
+// records every lookup by result, the time loading missing entries takes
+// and, for stores that report them, the size and capacity of the cache.
+// Evictions are recorded with ObserveEviction, from the eviction callback of
+// the store, which knows whether an entry was actually removed.
+package cachemetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Store is a cache. Get reports whether key was found; a miss is not an
+// error.
+type Store[K comparable, V any] interface {
+	Get(ctx context.Context, key K) (V, bool, error)
+	Set(ctx context.Context, key K, value V) error
+	Delete(ctx context.Context, key K) error
+}
+
+// Sizer is implemented by stores that know how many entries, or bytes, they
+// hold. The size is recorded after every Set and Delete.
+type Sizer interface {
+	Len() int
+}
+
+// Capper is implemented by stores with a capacity. The capacity is recorded
+// when the store is wrapped.
+type Capper interface {
+	Cap() int
+}
+
+// Cache is a Store whose calls are recorded with a Cache strategy.
+type Cache[K comparable, V any] struct {
+	store  Store[K, V]
+	cache  *strategy.Cache
+	labels []string
+}
+
+var _ Store[string, int] = (*Cache[string, int])(nil)
+
+// Wrap returns store recorded with cache, labelled with labels, the values
+// of the labels cache was created with.
+func Wrap[K comparable, V any](cache *strategy.Cache, store Store[K, V], labels ...string) *Cache[K, V] {
+	c := &Cache[K, V]{
+		store:  store,
+		cache:  cache,
+		labels: append([]string(nil), labels...),
+	}
+
+	if capper, ok := store.(Capper); ok {
+		cache.SetCapacity(float64(capper.Cap()), c.labels...)
+	}
+	c.observeSize()
+
+	return c
+}
+
+// Get looks key up and records the lookup as a hit, a miss or an error.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, bool, error) {
+	value, found, err := c.store.Get(ctx, key)
+	switch {
+	case err != nil:
+		c.cache.ObserveLookup(strategy.CacheError, c.labels...)
+	case found:
+		c.cache.ObserveLookup(strategy.CacheHit, c.labels...)
+	default:
+		c.cache.ObserveLookup(strategy.CacheMiss, c.labels...)
+	}
+
+	return value, found, err
+}
+
+// Set stores value under key.
+func (c *Cache[K, V]) Set(ctx context.Context, key K, value V) error {
+	err := c.store.Set(ctx, key, value)
+	c.observeSize()
+
+	return err
+}
+
+// Delete removes key. It records no eviction, as a store doesn't report
+// whether key was there; stores calling back on removals record them with
+// ObserveEviction.
+func (c *Cache[K, V]) Delete(ctx context.Context, key K) error {
+	err := c.store.Delete(ctx, key)
+	c.observeSize()
+
+	return err
+}
+
+// GetOrLoad looks key up and, on a miss, loads the value with load, timing
+// it into the load duration, and stores it. Lookup errors are returned
+// without loading. A value that fails to be stored is still returned.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, load func(ctx context.Context, key K) (V, error)) (V, error) {
+	value, found, err := c.Get(ctx, key)
+	if err != nil || found {
+		return value, err
+	}
+
+	start := time.Now()
+	value, err = load(ctx, key)
+	c.cache.ObserveLoad(time.Since(start).Seconds(), c.labels...)
+	if err != nil {
+		return value, err
+	}
+
+	_ = c.Set(ctx, key, value)
+
+	return value, nil
+}
+
+// ObserveEviction records that the store evicted an entry for reason, e.g.
+// from the eviction callback of an LRU cache. It doesn't call the store, so
+// it is safe to call while the store holds its locks.
+func (c *Cache[K, V]) ObserveEviction(reason string) {
+	c.cache.ObserveEviction(reason, c.labels...)
+}
+
+func (c *Cache[K, V]) observeSize() {
+	if sizer, ok := c.store.(Sizer); ok {
+		c.cache.SetSize(float64(sizer.Len()), c.labels...)
+	}
+}
@@ -0,0 +1,175 @@
+package cachemetrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// mapStore is a Store of bounded capacity evicting an arbitrary entry when
+// full, with an eviction callback.
+type mapStore struct {
+	mu       sync.Mutex
+	entries  map[string]int
+	capacity int
+	onEvict  func(reason string)
+	fail     bool
+}
+
+func newMapStore(capacity int) *mapStore {
+	return &mapStore{entries: map[string]int{}, capacity: capacity}
+}
+
+func (s *mapStore) Get(_ context.Context, key string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return 0, false, errUnavailable
+	}
+
+	value, ok := s.entries[key]
+
+	return value, ok, nil
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) == s.capacity {
+		for k := range s.entries {
+			delete(s.entries, k)
+			if s.onEvict != nil {
+				s.onEvict("capacity")
+			}
+
+			break
+		}
+	}
+	s.entries[key] = value
+
+	return nil
+}
+
+func (s *mapStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+
+	return nil
+}
+
+func (s *mapStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+func (s *mapStore) Cap() int {
+	return s.capacity
+}
+
+func newTestCache(t *testing.T) *strategy.Cache {
+	t.Helper()
+
+	cache, err := strategy.NewCache(strategy.CacheOpts{
+		Namespace: "service",
+		Labels:    []string{"cache"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cache
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	metrics := newTestCache(t)
+	store := newMapStore(2)
+	cache := Wrap[string, int](metrics, store, "users")
+	store.onEvict = cache.ObserveEviction
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Capacity.WithLabelValues("users")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.Size.WithLabelValues("users")))
+
+	ctx := context.Background()
+	_, found, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, cache.Set(ctx, "a", 1))
+	value, found, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1, value)
+
+	assert.Equal(t, 0.5, testutil.ToFloat64(metrics.Utilization.WithLabelValues("users")))
+
+	assert.NoError(t, cache.Set(ctx, "b", 2))
+	assert.NoError(t, cache.Set(ctx, "c", 3))
+	assert.NoError(t, cache.Delete(ctx, "c"))
+	assert.NoError(t, cache.Delete(ctx, "missing"))
+
+	store.fail = true
+	_, _, err = cache.Get(ctx, "a")
+	assert.ErrorIs(t, err, errUnavailable)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheError)))
+	// Deletes are not evictions.
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.Evictions))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Evictions.WithLabelValues("users", "capacity")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Size.WithLabelValues("users")))
+	assert.Equal(t, 0.5, testutil.ToFloat64(metrics.Utilization.WithLabelValues("users")))
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	metrics := newTestCache(t)
+	store := newMapStore(10)
+	cache := Wrap[string, int](metrics, store, "users")
+
+	loads := 0
+	load := func(_ context.Context, key string) (int, error) {
+		loads++
+		if key == "missing" {
+			return 0, errUnavailable
+		}
+
+		return len(key), nil
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		value, err := cache.GetOrLoad(ctx, "abc", load)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, value)
+	}
+
+	_, err := cache.GetOrLoad(ctx, "missing", load)
+	assert.ErrorIs(t, err, errUnavailable)
+
+	store.fail = true
+	_, err = cache.GetOrLoad(ctx, "abc", load)
+	assert.ErrorIs(t, err, errUnavailable)
+
+	assert.Equal(t, 2, loads)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheHit)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Lookups.WithLabelValues("users", strategy.CacheError)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.LoadDuration.Histogram))
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// wrapper is the template view of a target.
+type wrapper struct {
+	Type string
+	// Kind is the name of the strategy as used in prose, e.g. "RED".
+	Kind         string
+	StrategyType string
+	Constructor  string
+	Recorder     string
+	Opts         string
+	Methods      []method
+	Names        []metricName
+}
+
+// method is a named-argument record method of a wrapper.
+type method struct {
+	Name string
+	Doc  string
+	// Call is the recorder method the record method forwards to.
+	Call string
+	// Value is the name of the observed value parameter, if any.
+	Value  string
+	Labels []string
+	Params []string
+	// Fake is the strategytest accessor returning the recorded calls.
+	Fake string
+}
+
+// metricName pins the value of a metric name accessor in the generated test.
+type metricName struct {
+	Accessor string
+	Value    string
+}
+
+// ParamList renders the method parameters, e.g. "seconds float64, path string".
+// Metrics without labels take no label parameter.
+func (m method) ParamList() string {
+	var list []string
+	if m.Value != "" {
+		list = append(list, m.Value+" float64")
+	}
+	if len(m.Params) > 0 {
+		list = append(list, strings.Join(m.Params, ", ")+" string")
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// ArgList renders the arguments forwarded to the recorder.
+func (m method) ArgList() string {
+	args := m.Params
+	if m.Value != "" {
+		args = append([]string{m.Value}, args...)
+	}
+
+	return strings.Join(args, ", ")
+}
+
+// TestArgList renders arguments that pass each label its own name.
+func (m method) TestArgList() string {
+	var args []string
+	if m.Value != "" {
+		args = append(args, "1")
+	}
+	for _, l := range m.Labels {
+		args = append(args, strconv.Quote(l))
+	}
+
+	return strings.Join(args, ", ")
+}
+
+// checkParams reports labels that can't be turned into parameters: labels
+// without letters or digits, and labels turned into the same parameter,
+// such as "status_code" and "statusCode".
+func (m method) checkParams() error {
+	labels := map[string]string{}
+	for i, param := range m.Params {
+		label := m.Labels[i]
+		if param == "" {
+			return fmt.Errorf("%s: label %q has no letters or digits to name a parameter", m.Name, label)
+		}
+		if previous, ok := labels[param]; ok {
+			return fmt.Errorf("%s: labels %q and %q both generate parameter %s", m.Name, previous, label, param)
+		}
+		labels[param] = label
+	}
+
+	return nil
+}
+
+// QuotedLabels renders the labels as the elements of a string slice literal.
+func (m method) QuotedLabels() string {
+	return quoteAll(m.Labels)
+}
+
+func quoteAll(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = strconv.Quote(s)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func newWrapper(t target) (wrapper, error) {
+	switch {
+	case t.RED != nil:
+		red, err := strategy.NewRED(*t.RED)
+		if err != nil {
+			return wrapper{}, fmt.Errorf("%s: %w", t.Type, err)
+		}
+
+		return wrapper{
+			Type:         t.Type,
+			Kind:         "RED",
+			StrategyType: "RED",
+			Constructor:  "NewRED",
+			Recorder:     "REDRecorder",
+			Opts:         literal(reflect.ValueOf(*t.RED)),
+			Methods: []method{
+				newMethod("RecordRequest", "records that a request took place.", "ObserveRequest", "", t.RED.RequestsOpt.RequestLabels, "Requests"),
+				newMethod("RecordError", "records that a request failed.", "ObserveError", "", t.RED.ErrorsOpt.ErrorLabels, "Errors"),
+				newMethod("RecordDuration", "records how long a request took in seconds.", "ObserveDuration", "seconds", t.RED.DurationOpt.DurationLabels, "Durations"),
+			},
+			Names: []metricName{
+				{"RequestMetricName", red.RequestMetricName()},
+				{"ErrorMetricName", red.ErrorMetricName()},
+				{"DurationMetricName", red.DurationMetricName()},
+			},
+		}, nil
+	case t.USE != nil:
+		use, err := strategy.NewUSE(*t.USE)
+		if err != nil {
+			return wrapper{}, fmt.Errorf("%s: %w", t.Type, err)
+		}
+
+		return wrapper{
+			Type:         t.Type,
+			Kind:         "USE",
+			StrategyType: "USE",
+			Constructor:  "NewUSE",
+			Recorder:     "USERecorder",
+			Opts:         literal(reflect.ValueOf(*t.USE)),
+			Methods: []method{
+				newMethod("SetUtilization", "records how busy the resource is.", "SetUtilization", "value", t.USE.UtilizationOpt.UtilizationLabels, "Utilization"),
+				newMethod("SetSaturation", "records how much extra work is queued (or denied).", "SetSaturation", "value", t.USE.SaturationOpt.SaturationLabels, "Saturation"),
+				newMethod("RecordError", "records that the resource failed to service work.", "ObserveError", "", t.USE.ErrorsOpt.ErrorLabels, "Errors"),
+			},
+			Names: []metricName{
+				{"UtilizationMetricName", use.UtilizationMetricName()},
+				{"SaturationMetricName", use.SaturationMetricName()},
+				{"ErrorMetricName", use.ErrorMetricName()},
+			},
+		}, nil
+	case t.FGS != nil:
+		fgs, err := strategy.NewFourGoldenSignals(*t.FGS)
+		if err != nil {
+			return wrapper{}, fmt.Errorf("%s: %w", t.Type, err)
+		}
+
+		return wrapper{
+			Type:         t.Type,
+			Kind:         "Four Golden Signals",
+			StrategyType: "FourGoldenSignals",
+			Constructor:  "NewFourGoldenSignals",
+			Recorder:     "FGSRecorder",
+			Opts:         literal(reflect.ValueOf(*t.FGS)),
+			Methods: []method{
+				newMethod("RecordLatency", "records how long a request took in seconds.", "ObserveLatency", "seconds", t.FGS.LatencyOpt.LatencyLabels, "Latency"),
+				newMethod("RecordTraffic", "records that a request took place.", "ObserveTraffic", "", t.FGS.TrafficOpt.TrafficLabels, "Traffic"),
+				newMethod("RecordError", "records that a request failed.", "ObserveError", "", t.FGS.ErrorsOpt.ErrorLabels, "Errors"),
+				newMethod("SetSaturation", "records how \"full\" the service is.", "SetSaturation", "value", t.FGS.SaturationOpt.SaturationLabels, "Saturation"),
+			},
+			Names: []metricName{
+				{"LatencyMetricName", fgs.LatencyMetricName()},
+				{"TrafficMetricName", fgs.TrafficMetricName()},
+				{"ErrorMetricName", fgs.ErrorMetricName()},
+				{"SaturationMetricName", fgs.SaturationMetricName()},
+			},
+		}, nil
+	default:
+		return wrapper{}, fmt.Errorf("%s: no strategy options", t.Type)
+	}
+}
+
+func newMethod(name, doc, call, value string, labels []string, fake string) method {
+	params := make([]string, len(labels))
+	for i, l := range labels {
+		params[i] = paramName(l)
+	}
+
+	return method{
+		Name:   name,
+		Doc:    doc,
+		Call:   call,
+		Value:  value,
+		Labels: labels,
+		Params: params,
+		Fake:   fake,
+	}
+}
+
+// reserved are identifiers used by the generated methods themselves.
+var reserved = map[string]bool{"m": true, "seconds": true, "value": true}
+
+// paramName turns a label such as "status_code" into the parameter name
+// "statusCode", renaming labels that would clash with Go keywords or
+// predeclared identifiers. Labels made of separators only have no name.
+func paramName(label string) string {
+	parts := strings.FieldsFunc(label, func(r rune) bool { return r == '_' || r == '-' })
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	name := strings.Join(parts, "")
+	if name == "" {
+		return ""
+	}
+
+	if token.IsKeyword(name) || types.Universe.Lookup(name) != nil || reserved[name] {
+		return "label" + strings.ToUpper(name[:1]) + name[1:]
+	}
+
+	return name
+}
+
+// literal renders an options struct as a Go composite literal, leaving out
+// zero fields.
+func literal(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Struct:
+		var fields []string
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).IsZero() {
+				continue
+			}
+			fields = append(fields, fmt.Sprintf("%s: %s,\n", v.Type().Field(i).Name, literal(v.Field(i))))
+		}
+
+		return fmt.Sprintf("strategy.%s{\n%s}", v.Type().Name(), strings.Join(fields, ""))
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Slice:
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = literal(v.Index(i))
+		}
+
+		return fmt.Sprintf("%s{%s}", v.Type(), strings.Join(elems, ", "))
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Float() < keys[j].Float() })
+
+		elems := make([]string, len(keys))
+		for i, k := range keys {
+			elems[i] = fmt.Sprintf("%s: %s", literal(k), literal(v.MapIndex(k)))
+		}
+
+		return fmt.Sprintf("%s{%s}", v.Type(), strings.Join(elems, ", "))
+	default:
+		panic(fmt.Sprintf("promstrap-gen: unsupported option kind %s", v.Kind()))
+	}
+}
+
+// generate renders the wrappers of targets into a Go source file and its test.
+func generate(pkg string, targets []target) ([]byte, []byte, error) {
+	wrappers := make([]wrapper, len(targets))
+	for i, t := range targets {
+		w, err := newWrapper(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range w.Methods {
+			if err := m.checkParams(); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", t.Type, err)
+			}
+		}
+		wrappers[i] = w
+	}
+
+	data := struct {
+		Package  string
+		Wrappers []wrapper
+	}{pkg, wrappers}
+
+	src, err := render(sourceTemplate, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	test, err := render(testTemplate, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return src, test, nil
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+
+	return src, nil
+}
+
+var sourceTemplate = template.Must(template.New("source").Parse(`// Code generated by promstrap-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/rabellamy/promstrap/strategy"
+{{range .Wrappers}}{{$type := .Type}}
+// {{.Type}} records a {{.Kind}} strategy with named label arguments.
+type {{.Type}} struct {
+	// Strategy is the Prometheus backed strategy. It is nil when {{.Type}} was
+	// created with New{{.Type}}WithRecorder.
+	Strategy *strategy.{{.StrategyType}}
+
+	recorder strategy.{{.Recorder}}
+}
+
+// New{{.Type}} creates {{.Type}} backed by a {{.Kind}} strategy.
+func New{{.Type}}() (*{{.Type}}, error) {
+	s, err := strategy.{{.Constructor}}({{.Opts}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &{{.Type}}{Strategy: s, recorder: s}, nil
+}
+
+// New{{.Type}}WithRecorder creates {{.Type}} backed by recorder, such as a
+// no-op or a strategytest fake.
+func New{{.Type}}WithRecorder(recorder strategy.{{.Recorder}}) *{{.Type}} {
+	return &{{.Type}}{recorder: recorder}
+}
+
+// Register registers the strategy with the Prometheus DefaultRegisterer.
+func (m *{{.Type}}) Register() error {
+	if m.Strategy == nil {
+		return nil
+	}
+
+	return m.Strategy.Register()
+}
+{{range .Methods}}
+// {{.Name}} {{.Doc}}
+func (m *{{$type}}) {{.Name}}({{.ParamList}}) {
+	m.recorder.{{.Call}}({{.ArgList}})
+}
+{{end}}{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by promstrap-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rabellamy/promstrap/strategy/strategytest"
+)
+{{range .Wrappers}}{{$type := .Type}}
+func Test{{.Type}}MetricNames(t *testing.T) {
+	t.Parallel()
+
+	m, err := New{{.Type}}()
+	if err != nil {
+		t.Fatal(err)
+	}
+{{range .Names}}
+	if got, want := m.Strategy.{{.Accessor}}(), {{printf "%q" .Value}}; got != want {
+		t.Errorf("{{.Accessor}}() = %q, want %q", got, want)
+	}
+{{end}}}
+
+func Test{{.Type}}LabelOrder(t *testing.T) {
+	t.Parallel()
+
+	fake := &strategytest.{{.StrategyType}}{}
+	m := New{{.Type}}WithRecorder(fake)
+{{range .Methods}}
+	m.{{.Name}}({{.TestArgList}})
+	if got, want := fake.{{.Fake}}()[0].Labels, {{if .Labels}}[]string{ {{- .QuotedLabels -}} }{{else}}[]string(nil){{end}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("{{.Name}}() labels = %v, want %v", got, want)
+	}
+{{end}}}
+{{end}}`))
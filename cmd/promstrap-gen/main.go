@@ -0,0 +1,75 @@
+// Command promstrap-gen generates typed wrappers around RED, USE and Four
+// Golden Signals strategies so that label order and meaning live in the type
+// system instead of the caller's head.
+//
+// Instead of
+//
+//	red.Requests.WithLabelValues("/happy", "GET").Inc()
+//
+// callers write
+//
+//	api.RecordRequest(path, verb)
+//
+// The strategies are read either from a config document, as understood by the
+// config package, or from a Go file with structs annotated with a
+// //promstrap:red, //promstrap:use or //promstrap:fgs directive. For each
+// strategy a typed constructor, named-argument record methods and a golden
+// test pinning the metric names and label order are generated.
+//
+// Usage:
+//
+//	//go:generate promstrap-gen -in metrics.yaml -out ./apimetrics
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	in := flag.String("in", "", "strategy spec, a .yaml, .yml or .json config document or an annotated .go file")
+	out := flag.String("out", ".", "directory to write the generated package to")
+	pkg := flag.String("package", "", "name of the generated package, defaults to the base name of -out")
+	flag.Parse()
+
+	if err := run(*in, *out, *pkg); err != nil {
+		fmt.Fprintf(os.Stderr, "promstrap-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg string) error {
+	if in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	if pkg == "" {
+		abs, err := filepath.Abs(out)
+		if err != nil {
+			return err
+		}
+		pkg = filepath.Base(abs)
+	}
+
+	targets, err := loadTargets(in)
+	if err != nil {
+		return err
+	}
+
+	src, test, err := generate(pkg, targets)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(out, "promstrap_gen.go"), src, 0o644); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(out, "promstrap_gen_test.go"), test, 0o644)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in  string
+		pkg string
+	}{
+		"config document": {
+			in:  "testdata/api.yaml",
+			pkg: "apimetrics",
+		},
+		"annotated struct": {
+			in:  "testdata/frontend.go",
+			pkg: "frontendmetrics",
+		},
+	}
+
+	for name, tt := range tests {
+		in := tt.in
+		pkg := tt.pkg
+
+		t.Run(name, func(t *testing.T) {
+			targets, err := loadTargets(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			src, test, err := generate(pkg, targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			base := strings.TrimSuffix(in, filepath.Ext(in))
+			assertGolden(t, base+".go.golden", src)
+			assertGolden(t, base+"_test.go.golden", test)
+		})
+	}
+}
+
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestLoadTargetsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		src     string
+		wantErr string
+	}{
+		"unknown strategy": {
+			src: `package p
+
+//promstrap:abc namespace=service
+type API struct{}
+`,
+			wantErr: `api.go:4:6: unknown strategy "abc", want red, use or fgs`,
+		},
+		"unknown metric": {
+			src: `package p
+
+//promstrap:red namespace=service
+type API struct {
+	Requests struct{ Path string } ` + "`type:\"http\"`" + `
+	Latency  struct{ Path string }
+}
+`,
+			wantErr: `api.go:4:6: unknown red metric "Latency", want one of Requests, Errors, Duration`,
+		},
+		"metric is not a struct": {
+			src: `package p
+
+//promstrap:red namespace=service
+type API struct {
+	Requests string
+}
+`,
+			wantErr: "api.go:5:2: metric fields must be named structs of labels",
+		},
+		"no annotated structs": {
+			src: `package p
+
+type API struct{}
+`,
+			wantErr: "no structs annotated",
+		},
+	}
+
+	for name, tt := range tests {
+		src := tt.src
+		wantErr := tt.wantErr
+
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "api.go")
+			if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := loadTargets(path)
+			assert.ErrorContains(t, err, wantErr)
+		})
+	}
+}
+
+func TestLoadConfigTargetsTypeCollision(t *testing.T) {
+	t.Parallel()
+
+	doc := `red:
+  db:
+    namespace: service
+    requests: {type: sql, labels: [query]}
+    errors: {labels: [query]}
+    duration: {labels: [query]}
+use:
+  db:
+    namespace: service
+    utilization: {name: db_pool_utilization_ratio, help: Pool utilization, labels: [pool]}
+    saturation: {name: db_pool_saturation_waits, help: Pool waits, labels: [pool]}
+    errors: {labels: [pool]}
+`
+
+	path := filepath.Join(t.TempDir(), "metrics.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadTargets(path)
+	assert.ErrorContains(t, err, `red "db" and use "db" both generate type Db`)
+}
+
+func TestGenerateInvalidOpts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "api.go")
+	src := `package p
+
+//promstrap:red
+type API struct {
+	Requests struct{ Path string } ` + "`type:\"http\"`" + `
+	Errors   struct{ Error string }
+	Duration struct{ Path string }
+}
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := loadTargets(path)
+	assert.NoError(t, err)
+
+	_, _, err = generate("p", targets)
+	assert.ErrorContains(t, err, "REDOpts.Namespace")
+}
+
+func TestGenerateLabelParams(t *testing.T) {
+	t.Parallel()
+
+	red := func(labels ...string) []target {
+		return []target{{Type: "API", RED: &strategy.REDOpts{
+			Namespace:   "service",
+			RequestsOpt: strategy.REDRequestsOpt{RequestType: "http", RequestLabels: labels},
+			ErrorsOpt:   strategy.REDErrorsOpt{ErrorLabels: labels},
+			DurationOpt: strategy.REDDurationOpt{DurationLabels: labels},
+		}}}
+	}
+
+	tests := map[string]struct {
+		targets []target
+		wantErr string
+	}{
+		"separators only": {
+			targets: red("path", "__"),
+			wantErr: `API: RecordRequest: label "__" has no letters or digits to name a parameter`,
+		},
+		"same parameter": {
+			targets: red("status_code", "statusCode"),
+			wantErr: `API: RecordRequest: labels "status_code" and "statusCode" both generate parameter statusCode`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := generate("p", tc.targets)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestGenerateNoLabels(t *testing.T) {
+	t.Parallel()
+
+	targets := []target{{Type: "API", RED: &strategy.REDOpts{
+		Namespace:   "service",
+		RequestsOpt: strategy.REDRequestsOpt{RequestType: "http", RequestLabels: []string{}},
+		ErrorsOpt:   strategy.REDErrorsOpt{ErrorLabels: []string{}},
+		DurationOpt: strategy.REDDurationOpt{DurationLabels: []string{}},
+	}}}
+
+	src, test, err := generate("p", targets)
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func (m *API) RecordRequest() {\n\tm.recorder.ObserveRequest()\n}")
+	assert.Contains(t, string(src), "func (m *API) RecordDuration(seconds float64) {\n\tm.recorder.ObserveDuration(seconds)\n}")
+	assert.Contains(t, string(test), "m.RecordDuration(1)")
+}
+
+func TestParamName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"path":        "path",
+		"status_code": "statusCode",
+		"type":        "labelType",
+		"value":       "labelValue",
+		"m":           "labelM",
+		"error":       "labelError",
+		"__":          "",
+	}
+
+	for label, want := range tests {
+		assert.Equal(t, want, paramName(label), label)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"Path":       "path",
+		"StatusCode": "status_code",
+		"GCType":     "gc_type",
+		"HTTPMethod": "http_method",
+	}
+
+	for name, want := range tests {
+		assert.Equal(t, want, snakeCase(name), name)
+	}
+}
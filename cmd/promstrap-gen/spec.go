@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rabellamy/promstrap/config"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// target is a single typed wrapper to generate. Exactly one of the opts is set.
+type target struct {
+	Type string
+	RED  *strategy.REDOpts
+	USE  *strategy.USEOpts
+	FGS  *strategy.FourGoldenSignalsOpts
+}
+
+// loadTargets reads the strategies declared in path, either a config document
+// or a Go file with annotated structs.
+func loadTargets(path string) ([]target, error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return loadConfigTargets(path)
+	case ".go":
+		return loadGoTargets(path)
+	default:
+		return nil, fmt.Errorf("%s: unsupported spec, want .yaml, .yml, .json or .go", path)
+	}
+}
+
+// loadConfigTargets generates a wrapper for every red, use and fgs entry of a
+// config document, named after the entry.
+func loadConfigTargets(path string) ([]target, error) {
+	set, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []target
+	// declared maps the type names to the entries they were generated from,
+	// as entries of different sections or spellings can share one.
+	declared := map[string]string{}
+	add := func(section, name string, t target) error {
+		t.Type = exportedName(name)
+		entry := fmt.Sprintf("%s %q", section, name)
+		if previous, ok := declared[t.Type]; ok {
+			return fmt.Errorf("%s: %s and %s both generate type %s", path, previous, entry, t.Type)
+		}
+		declared[t.Type] = entry
+		targets = append(targets, t)
+
+		return nil
+	}
+
+	for _, name := range sortedKeys(set.RED) {
+		opts := set.RED[name].Opts()
+		if err := add("red", name, target{RED: &opts}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(set.USE) {
+		opts := set.USE[name].Opts()
+		if err := add("use", name, target{USE: &opts}); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(set.FGS) {
+		opts := set.FGS[name].Opts()
+		if err := add("fgs", name, target{FGS: &opts}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s: no red, use or fgs strategies declared", path)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Type < targets[j].Type
+	})
+
+	return targets, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// exportedName turns a config entry name such as "http_api" into "HttpApi".
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	return b.String()
+}
+
+const directive = "//promstrap:"
+
+// role is a field of an annotated struct, one per metric of the strategy.
+type role struct {
+	labels []string
+	tag    reflect.StructTag
+}
+
+// loadGoTargets generates a wrapper for every struct in a Go file annotated
+// with a //promstrap:red, //promstrap:use or //promstrap:fgs directive.
+//
+//	//promstrap:red namespace=service
+//	type API struct {
+//		Requests struct {
+//			Path string
+//			Verb string
+//		} `type:"http"`
+//		Errors struct {
+//			Error string
+//		}
+//		Duration struct {
+//			Path string
+//		} `buckets:"0.05,0.1,0.25,0.5,1"`
+//	}
+//
+// Every field names a metric of the strategy and its own fields are the labels
+// of that metric, snake_cased unless a `label` tag is set. The tags of a metric
+// field set its name, help, type, buckets and objectives.
+func loadGoTargets(path string) ([]target, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []target
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, s := range gen.Specs {
+			spec := s.(*ast.TypeSpec)
+
+			doc := spec.Doc
+			if doc == nil {
+				doc = gen.Doc
+			}
+			kind, args, ok := findDirective(doc)
+			if !ok {
+				continue
+			}
+
+			t, err := goTarget(fset, spec, kind, args)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, t)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s: no structs annotated with %sred, %suse or %sfgs", path, directive, directive, directive)
+	}
+
+	return targets, nil
+}
+
+func findDirective(doc *ast.CommentGroup) (string, map[string]string, bool) {
+	if doc == nil {
+		return "", nil, false
+	}
+
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, directive) {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(c.Text, directive))
+		if len(fields) == 0 {
+			continue
+		}
+
+		args := map[string]string{}
+		for _, f := range fields[1:] {
+			k, v, _ := strings.Cut(f, "=")
+			args[k] = v
+		}
+
+		return fields[0], args, true
+	}
+
+	return "", nil, false
+}
+
+func goTarget(fset *token.FileSet, spec *ast.TypeSpec, kind string, args map[string]string) (target, error) {
+	errorf := func(pos token.Pos, format string, a ...interface{}) error {
+		return fmt.Errorf("%s: %s", fset.Position(pos), fmt.Sprintf(format, a...))
+	}
+
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return target{}, errorf(spec.Pos(), "%s must be a struct", spec.Name.Name)
+	}
+
+	roles := map[string]role{}
+	for _, field := range st.Fields.List {
+		metric, ok := field.Type.(*ast.StructType)
+		if !ok || len(field.Names) != 1 {
+			return target{}, errorf(field.Pos(), "metric fields must be named structs of labels")
+		}
+
+		var r role
+		if field.Tag != nil {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return target{}, errorf(field.Tag.Pos(), "%v", err)
+			}
+			r.tag = reflect.StructTag(tag)
+		}
+
+		for _, label := range metric.Fields.List {
+			var tag reflect.StructTag
+			if label.Tag != nil {
+				unquoted, err := strconv.Unquote(label.Tag.Value)
+				if err != nil {
+					return target{}, errorf(label.Tag.Pos(), "%v", err)
+				}
+				tag = reflect.StructTag(unquoted)
+			}
+
+			for _, n := range label.Names {
+				name := tag.Get("label")
+				if name == "" {
+					name = snakeCase(n.Name)
+				}
+				r.labels = append(r.labels, name)
+			}
+		}
+
+		roles[field.Names[0].Name] = r
+	}
+
+	t := target{Type: spec.Name.Name}
+
+	var allowed []string
+	switch kind {
+	case "red":
+		allowed = []string{"Requests", "Errors", "Duration"}
+		buckets, objectives, err := distributionTags(roles["Duration"].tag)
+		if err != nil {
+			return target{}, errorf(spec.Pos(), "Duration: %v", err)
+		}
+		t.RED = &strategy.REDOpts{
+			Namespace: args["namespace"],
+			RequestsOpt: strategy.REDRequestsOpt{
+				RequestName:   roles["Requests"].tag.Get("name"),
+				RequestType:   roles["Requests"].tag.Get("type"),
+				RequestLabels: roles["Requests"].labels,
+			},
+			ErrorsOpt: strategy.REDErrorsOpt{
+				ErrorName:   roles["Errors"].tag.Get("name"),
+				ErrorLabels: roles["Errors"].labels,
+			},
+			DurationOpt: strategy.REDDurationOpt{
+				DurationName:   roles["Duration"].tag.Get("name"),
+				DurationLabels: roles["Duration"].labels,
+				Buckets:        buckets,
+				Objectives:     objectives,
+			},
+		}
+	case "use":
+		allowed = []string{"Utilization", "Saturation", "Errors"}
+		t.USE = &strategy.USEOpts{
+			Namespace: args["namespace"],
+			UtilizationOpt: strategy.USEUtilizationOpt{
+				UtilizationName:   roles["Utilization"].tag.Get("name"),
+				UtilizationHelp:   roles["Utilization"].tag.Get("help"),
+				UtilizationLabels: roles["Utilization"].labels,
+			},
+			SaturationOpt: strategy.USESaturationOpt{
+				SaturationName:   roles["Saturation"].tag.Get("name"),
+				SaturationHelp:   roles["Saturation"].tag.Get("help"),
+				SaturationLabels: roles["Saturation"].labels,
+			},
+			ErrorsOpt: strategy.USEErrorsOpt{
+				ErrorName:   roles["Errors"].tag.Get("name"),
+				ErrorLabels: roles["Errors"].labels,
+			},
+		}
+	case "fgs":
+		allowed = []string{"Latency", "Traffic", "Errors", "Saturation"}
+		buckets, objectives, err := distributionTags(roles["Latency"].tag)
+		if err != nil {
+			return target{}, errorf(spec.Pos(), "Latency: %v", err)
+		}
+		t.FGS = &strategy.FourGoldenSignalsOpts{
+			Namespace: args["namespace"],
+			LatencyOpt: strategy.FGSLatencyOpt{
+				LatencyName:   roles["Latency"].tag.Get("name"),
+				LatencyType:   roles["Latency"].tag.Get("type"),
+				LatencyHelp:   roles["Latency"].tag.Get("help"),
+				LatencyLabels: roles["Latency"].labels,
+				Buckets:       buckets,
+				Objectives:    objectives,
+			},
+			TrafficOpt: strategy.FGSTrafficOpt{
+				TrafficName:   roles["Traffic"].tag.Get("name"),
+				TrafficType:   roles["Traffic"].tag.Get("type"),
+				TrafficHelp:   roles["Traffic"].tag.Get("help"),
+				TrafficLabels: roles["Traffic"].labels,
+			},
+			ErrorsOpt: strategy.FGSErrorsOpt{
+				ErrorName:   roles["Errors"].tag.Get("name"),
+				ErrorHelp:   roles["Errors"].tag.Get("help"),
+				ErrorLabels: roles["Errors"].labels,
+			},
+			SaturationOpt: strategy.FGSSaturationOpt{
+				SaturationName:   roles["Saturation"].tag.Get("name"),
+				SaturationHelp:   roles["Saturation"].tag.Get("help"),
+				SaturationLabels: roles["Saturation"].labels,
+			},
+		}
+	default:
+		return target{}, errorf(spec.Pos(), "unknown strategy %q, want red, use or fgs", kind)
+	}
+
+	for name := range roles {
+		if !contains(allowed, name) {
+			return target{}, errorf(spec.Pos(), "unknown %s metric %q, want one of %s", kind, name, strings.Join(allowed, ", "))
+		}
+	}
+
+	return t, nil
+}
+
+// distributionTags parses the `buckets:"0.1,1"` and `objectives:"0.5:0.05"`
+// tags of a distribution metric field.
+func distributionTags(tag reflect.StructTag) ([]float64, map[float64]float64, error) {
+	var buckets []float64
+	if raw := tag.Get("buckets"); raw != "" {
+		for _, b := range strings.Split(raw, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("bucket %q is not a number", b)
+			}
+			buckets = append(buckets, f)
+		}
+	}
+
+	var objectives map[float64]float64
+	if raw := tag.Get("objectives"); raw != "" {
+		objectives = map[float64]float64{}
+		for _, o := range strings.Split(raw, ",") {
+			q, e, ok := strings.Cut(strings.TrimSpace(o), ":")
+			quantile, qErr := strconv.ParseFloat(q, 64)
+			epsilon, eErr := strconv.ParseFloat(e, 64)
+			if !ok || qErr != nil || eErr != nil {
+				return nil, nil, fmt.Errorf("objective %q is not quantile:error", o)
+			}
+			objectives[quantile] = epsilon
+		}
+	}
+
+	return buckets, objectives, nil
+}
+
+// snakeCase turns a Go field name such as "StatusCode" into "status_code".
+func snakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+package frontend
+
+// Frontend is instrumented with the Four Golden Signals.
+//
+//promstrap:fgs namespace=service
+type Frontend struct {
+	Latency struct {
+		Method     string
+		StatusCode string
+	} `name:"http_request_latency_seconds" type:"http" help:"HTTP request latency in seconds" buckets:"0.005,0.01,0.1,1" objectives:"0.5:0.05,0.99:0.001"`
+	Traffic struct {
+		Method string
+		Route  string `label:"path"`
+	} `name:"http_server_requests_total" type:"http" help:"Total number of HTTP requests"`
+	Errors struct {
+		Type string
+	} `help:"Number of errors"`
+	Saturation struct {
+		GCType string
+	} `name:"memory_heap_saturation_bytes" help:"Memory heap usage in bytes"`
+}
+
+// Ignored is not annotated.
+type Ignored struct{}
@@ -0,0 +1,182 @@
+package collectors
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// CPUCollector reads the CPU usage of the cgroup, or of the host outside of
+// a cgroup, at scrape time and exposes it with USE semantics:
+//
+//	{namespace}_cpu_usage_seconds_total                  CPU time used
+//	{namespace}_cpu_capacity_cores                       CPU time available per second
+//	{namespace}_cpu_saturation_throttled_periods_total   CFS periods the cgroup was throttled in
+//	{namespace}_cpu_saturation_throttled_seconds_total   time the cgroup was throttled for
+//
+// The cgroup is the one of the process, read from /proc/self/cgroup. The
+// CPU time available is the CFS quota of the cgroup, or the CPUs of the
+// host when it has none. Throttling is only exposed inside a cgroup. CPU
+// time is cumulative, so any number of scrapers get the utilization over
+// their own window with PromQL:
+//
+//	rate({namespace}_cpu_usage_seconds_total[5m]) / {namespace}_cpu_capacity_cores
+type CPUCollector struct {
+	proc   procfs.FS
+	cgroup cgroup
+
+	usage             *prometheus.Desc
+	capacity          *prometheus.Desc
+	throttledPeriods  *prometheus.Desc
+	throttledDuration *prometheus.Desc
+}
+
+// NewCPUCollector creates a CPUCollector.
+func NewCPUCollector(opts LinuxOpts) (*CPUCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+
+	proc, err := procfs.NewFS(opts.procPath())
+	if err != nil {
+		return nil, err
+	}
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "cpu", name), help, nil, nil)
+	}
+
+	return &CPUCollector{
+		proc:              proc,
+		cgroup:            detectCgroup(proc, opts.Root),
+		usage:             desc("usage_seconds_total", "CPU time used in seconds"),
+		capacity:          desc("capacity_cores", "CPU time available per second, the CFS quota or the CPUs of the host"),
+		throttledPeriods:  desc("saturation_throttled_periods_total", "Number of CFS periods the cgroup was throttled in"),
+		throttledDuration: desc("saturation_throttled_seconds_total", "Time the cgroup was throttled for in seconds"),
+	}, nil
+}
+
+// cpuUsage is the CPU usage of a cgroup or of the host.
+type cpuUsage struct {
+	// seconds is the CPU time used.
+	seconds float64
+	// cores is the CPU time available per second.
+	cores float64
+	// throttled is whether the throttling fields are set.
+	throttled        bool
+	throttledPeriods float64
+	throttledSeconds float64
+}
+
+// Describe implements prometheus.Collector.
+func (c *CPUCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.usage
+	ch <- c.capacity
+	ch <- c.throttledPeriods
+	ch <- c.throttledDuration
+}
+
+// Collect implements prometheus.Collector.
+func (c *CPUCollector) Collect(ch chan<- prometheus.Metric) {
+	usage, err := c.read()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.usage, err)
+
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.usage, prometheus.CounterValue, usage.seconds)
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, usage.cores)
+
+	if usage.throttled {
+		ch <- prometheus.MustNewConstMetric(c.throttledPeriods, prometheus.CounterValue, usage.throttledPeriods)
+		ch <- prometheus.MustNewConstMetric(c.throttledDuration, prometheus.CounterValue, usage.throttledSeconds)
+	}
+}
+
+func (c *CPUCollector) read() (cpuUsage, error) {
+	stat, err := c.proc.Stat()
+	if err != nil {
+		return cpuUsage{}, err
+	}
+
+	t := stat.CPUTotal
+	usage := cpuUsage{
+		seconds: t.User + t.Nice + t.System + t.IRQ + t.SoftIRQ + t.Steal,
+		cores:   float64(len(stat.CPU)),
+	}
+
+	// Missing usage files leave the usage of the host, as at the root of a
+	// hierarchy.
+	switch c.cgroup.version {
+	case 2:
+		cpuStat, err := readKeyed(c.cgroup.path("cpu.stat"))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return cpuUsage{}, err
+		}
+		usage.seconds = float64(cpuStat["usage_usec"]) / 1e6
+		// The root of a hierarchy has no throttling.
+		_, usage.throttled = cpuStat["nr_throttled"]
+		usage.throttledPeriods = float64(cpuStat["nr_throttled"])
+		usage.throttledSeconds = float64(cpuStat["throttled_usec"]) / 1e6
+
+		if quota, period, ok := c.quotaV2(); ok {
+			usage.cores = quota / period
+		}
+	case 1:
+		used, _, err := readUint(c.cgroup.path("cpuacct.usage", "cpuacct", "cpu,cpuacct", "cpu"))
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return cpuUsage{}, err
+		}
+		usage.seconds = float64(used) / 1e9
+
+		if cpuStat, err := readKeyed(c.cgroup.path("cpu.stat", "cpu", "cpu,cpuacct")); err == nil {
+			usage.throttled = true
+			usage.throttledPeriods = float64(cpuStat["nr_throttled"])
+			usage.throttledSeconds = float64(cpuStat["throttled_time"]) / 1e9
+		}
+
+		quota, qerr := readInt(c.cgroup.path("cpu.cfs_quota_us", "cpu", "cpu,cpuacct"))
+		period, perr := readInt(c.cgroup.path("cpu.cfs_period_us", "cpu", "cpu,cpuacct"))
+		if qerr == nil && perr == nil && quota > 0 && period > 0 {
+			usage.cores = float64(quota) / float64(period)
+		}
+	}
+
+	return usage, nil
+}
+
+// quotaV2 reads the "quota period" of cpu.max. ok is false when the cgroup
+// has no quota.
+func (c *CPUCollector) quotaV2() (quota, period float64, ok bool) {
+	fields, err := readFields(c.cgroup.path("cpu.max"))
+	if err != nil || len(fields) != 2 || fields[0] == "max" {
+		return 0, 0, false
+	}
+
+	q, qerr := strconv.ParseFloat(fields[0], 64)
+	p, perr := strconv.ParseFloat(fields[1], 64)
+	if qerr != nil || perr != nil || p == 0 {
+		return 0, 0, false
+	}
+
+	return q, p, true
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *CPUCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPUCollector(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		root string
+		want map[string]float64
+	}{
+		"cgroup v2": {
+			root: "testdata/cgroup2",
+			want: map[string]float64{
+				"service_cpu_usage_seconds_total{}":                3,
+				"service_cpu_capacity_cores{}":                     0.5,
+				"service_cpu_saturation_throttled_periods_total{}": 10,
+				"service_cpu_saturation_throttled_seconds_total{}": 0.5,
+			},
+		},
+		"cgroup v1": {
+			root: "testdata/cgroup1",
+			want: map[string]float64{
+				"service_cpu_usage_seconds_total{}":                2,
+				"service_cpu_capacity_cores{}":                     2,
+				"service_cpu_saturation_throttled_periods_total{}": 5,
+				"service_cpu_saturation_throttled_seconds_total{}": 0.25,
+			},
+		},
+		"root of a cgroup v2 hierarchy": {
+			root: "testdata/cgroup2-root",
+			want: map[string]float64{
+				"service_cpu_usage_seconds_total{}": 4,
+				"service_cpu_capacity_cores{}":      2,
+			},
+		},
+		"host": {
+			root: "testdata/host",
+			want: map[string]float64{
+				"service_cpu_usage_seconds_total{}": 4,
+				"service_cpu_capacity_cores{}":      2,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewCPUCollector(LinuxOpts{Namespace: "service", Root: tc.root})
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.want, gather(t, c))
+		})
+	}
+}
+
+func TestCPUCollectorCumulative(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/stat":                        "cpu  0 0 0 0 0 0 0 0 0 0\ncpu0 0 0 0 0 0 0 0 0 0 0\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu memory\n",
+		"sys/fs/cgroup/cpu.max":            "50000 100000\n",
+		"sys/fs/cgroup/cpu.stat":           "usage_usec 3000000\n",
+	})
+
+	c, err := NewCPUCollector(LinuxOpts{Namespace: "service", Root: root})
+	assert.NoError(t, err)
+
+	// Scrapes don't reset anything, so a second scraper reads the same
+	// counter.
+	assert.Equal(t, gather(t, c), gather(t, c))
+
+	writeFiles(t, root, map[string]string{"sys/fs/cgroup/cpu.stat": "usage_usec 5500000\n"})
+
+	values := gather(t, c)
+	assert.Equal(t, 5.5, values["service_cpu_usage_seconds_total{}"])
+	assert.Equal(t, 0.5, values["service_cpu_capacity_cores{}"])
+}
+
+func TestCPUCollectorErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewCPUCollector(LinuxOpts{Root: "testdata/host"})
+	assert.ErrorContains(t, err, "LinuxOpts.Namespace")
+
+	_, err = NewCPUCollector(LinuxOpts{Namespace: "service", Root: "testdata/missing"})
+	assert.Error(t, err)
+
+	// A root without /proc/stat fails at scrape time.
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"proc/version": "Linux\n"})
+
+	c, err := NewCPUCollector(LinuxOpts{Namespace: "service", Root: root})
+	assert.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+	_, err = reg.Gather()
+	assert.Error(t, err)
+}
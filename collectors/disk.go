@@ -0,0 +1,100 @@
+package collectors
+
+import (
+	"regexp"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs/blockdevice"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// DiskCollector reads /proc/diskstats at scrape time and exposes the block
+// devices of the host with USE semantics, labelled by device:
+//
+//	{namespace}_disk_busy_seconds_total{device}     time the device was busy
+//	{namespace}_disk_saturation_queue_depth{device} I/Os in progress
+//
+// A device is busy for at most a second per second, so any number of
+// scrapers get the utilization over their own window with PromQL:
+//
+//	rate({namespace}_disk_busy_seconds_total[5m])
+type DiskCollector struct {
+	block   blockdevice.FS
+	ignored *regexp.Regexp
+
+	busy       *prometheus.Desc
+	queueDepth *prometheus.Desc
+}
+
+// DiskOpts is the options to create a DiskCollector.
+type DiskOpts struct {
+	LinuxOpts
+	// IgnoredDevices is a regular expression matching the devices not to
+	// expose. If not specified, defaults to RAM disks, loop and floppy
+	// devices.
+	IgnoredDevices string
+}
+
+// NewDiskCollector creates a DiskCollector.
+func NewDiskCollector(opts DiskOpts) (*DiskCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+	opts.LinuxOpts = opts.LinuxOpts.withDefaults()
+
+	if opts.IgnoredDevices == "" {
+		opts.IgnoredDevices = `^(ram|loop|fd)\d+$`
+	}
+	ignored, err := regexp.Compile(opts.IgnoredDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := blockdevice.NewFS(opts.procPath(), opts.sysPath())
+	if err != nil {
+		return nil, err
+	}
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "disk", name), help, []string{"device"}, nil)
+	}
+
+	return &DiskCollector{
+		block:      block,
+		ignored:    ignored,
+		busy:       desc("busy_seconds_total", "Time the device was busy in seconds"),
+		queueDepth: desc("saturation_queue_depth", "Number of I/Os in progress"),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *DiskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.busy
+	ch <- c.queueDepth
+}
+
+// Collect implements prometheus.Collector.
+func (c *DiskCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.block.ProcDiskstats()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.busy, err)
+
+		return
+	}
+
+	for _, s := range stats {
+		if c.ignored.MatchString(s.DeviceName) {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.busy, prometheus.CounterValue, float64(s.IOsTotalTicks)/1000, s.DeviceName)
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(s.IOsInProgress), s.DeviceName)
+	}
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *DiskCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
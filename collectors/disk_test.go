@@ -0,0 +1,35 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskCollector(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewDiskCollector(DiskOpts{LinuxOpts: LinuxOpts{Namespace: "service", Root: "testdata/host"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{
+		"service_disk_busy_seconds_total{sda}":      1.5,
+		"service_disk_busy_seconds_total{sda1}":     1,
+		"service_disk_saturation_queue_depth{sda}":  3,
+		"service_disk_saturation_queue_depth{sda1}": 1,
+	}, gather(t, c))
+}
+
+func TestDiskCollectorIgnoredDevices(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewDiskCollector(DiskOpts{LinuxOpts: LinuxOpts{Namespace: "service", Root: "testdata/host"}, IgnoredDevices: "("})
+	assert.ErrorContains(t, err, "missing closing )")
+
+	c, err := NewDiskCollector(DiskOpts{LinuxOpts: LinuxOpts{Namespace: "service", Root: "testdata/host"}, IgnoredDevices: `\d$`})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"service_disk_busy_seconds_total{sda}":     1.5,
+		"service_disk_saturation_queue_depth{sda}": 3,
+	}, gather(t, c))
+}
@@ -0,0 +1,244 @@
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+// LinuxOpts is the options to create the Linux collectors.
+type LinuxOpts struct {
+	Namespace string `validate:"required"`
+	// Root is the directory /proc and /sys are mounted in, e.g. a fixture
+	// tree in tests or "/host" in a container mounting the host ones. If not
+	// specified, defaults to "/".
+	Root string
+}
+
+func (o LinuxOpts) withDefaults() LinuxOpts {
+	if o.Root == "" {
+		o.Root = "/"
+	}
+
+	return o
+}
+
+func (o LinuxOpts) procPath() string {
+	return filepath.Join(o.Root, "proc")
+}
+
+func (o LinuxOpts) sysPath() string {
+	return filepath.Join(o.Root, "sys")
+}
+
+// cgroup is the cgroup of the process in the hierarchy mounted in
+// /sys/fs/cgroup.
+type cgroup struct {
+	// version is 1 or 2, or 0 when no hierarchy is mounted.
+	version int
+	root    string
+	// dirs are the paths of the cgroup relative to the root, by v1
+	// controller, or under "" on v2.
+	dirs map[string]string
+}
+
+// detectCgroup returns the cgroup of the process, read from
+// /proc/self/cgroup, in the hierarchy mounted under root. Inside a container
+// with its own cgroup namespace, it is the root of the hierarchy of the
+// container.
+func detectCgroup(proc procfs.FS, root string) cgroup {
+	dir := filepath.Join(root, "sys", "fs", "cgroup")
+	dirs := selfCgroups(proc)
+
+	if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+		return cgroup{version: 2, root: dir, dirs: dirs}
+	}
+	for _, controller := range []string{"cpu", "cpuacct", "cpu,cpuacct", "memory"} {
+		if _, err := os.Stat(filepath.Join(dir, controller)); err == nil {
+			return cgroup{version: 1, root: dir, dirs: dirs}
+		}
+	}
+
+	return cgroup{}
+}
+
+// selfCgroups returns the paths of the cgroups of the process by v1
+// controller, and by the comma separated controllers of their hierarchy,
+// e.g. "cpu,cpuacct". The v2 path is under "". It returns nil when
+// /proc/self/cgroup can't be read.
+func selfCgroups(proc procfs.FS) map[string]string {
+	self, err := proc.Self()
+	if err != nil {
+		return nil
+	}
+
+	cgroups, err := self.Cgroups()
+	if err != nil {
+		return nil
+	}
+
+	dirs := map[string]string{}
+	for _, cg := range cgroups {
+		if cg.HierarchyID == 0 {
+			dirs[""] = cg.Path
+
+			continue
+		}
+
+		dirs[strings.Join(cg.Controllers, ",")] = cg.Path
+		for _, controller := range cg.Controllers {
+			dirs[controller] = cg.Path
+		}
+	}
+
+	return dirs
+}
+
+// dir returns the directory of the cgroup in the hierarchy mounted in
+// mount, the root or a v1 controller directory. Without a cgroup namespace
+// the path of the cgroup is only found when the host hierarchy is mounted;
+// otherwise the mount is the one of the cgroup.
+func (c cgroup) dir(mount, controller string) string {
+	if path, ok := c.dirs[controller]; ok {
+		dir := filepath.Join(mount, path)
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+
+	return mount
+}
+
+// path returns the path of file in the cgroup, in the first of the v1
+// controller directories it exists in, or in the v2 hierarchy.
+func (c cgroup) path(file string, controllers ...string) string {
+	if c.version == 2 {
+		return filepath.Join(c.dir(c.root, ""), file)
+	}
+
+	for _, controller := range controllers {
+		p := filepath.Join(c.dir(filepath.Join(c.root, controller), controller), file)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+
+	return filepath.Join(c.dir(filepath.Join(c.root, controllers[0]), controllers[0]), file)
+}
+
+// readUint reads a file holding a single unsigned integer. ok is false when
+// the file holds "max", the unlimited value of cgroup v2.
+func readUint(path string) (v uint64, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, false, nil
+	}
+
+	v, err = strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return v, true, nil
+}
+
+// readInt reads a file holding a single signed integer.
+func readInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return v, nil
+}
+
+// readFields reads the whitespace separated fields of a file, such as
+// cpu.max.
+func readFields(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(data)), nil
+}
+
+// readKeyed reads a file of "key value" lines, such as cpu.stat or
+// memory.events.
+func readKeyed(path string) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		values[fields[0]] = v
+	}
+
+	return values, scanner.Err()
+}
+
+// readPressure reads the total stall time in seconds of the "some" and
+// "full" lines of a pressure stall information file, such as
+// memory.pressure.
+func readPressure(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[string]float64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, f := range fields[1:] {
+			total, ok := strings.CutPrefix(f, "total=")
+			if !ok {
+				continue
+			}
+
+			v, err := strconv.ParseUint(total, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			totals[fields[0]] = float64(v) / 1e6
+		}
+	}
+	if len(totals) == 0 {
+		return nil, errors.New(path + ": no total")
+	}
+
+	return totals, scanner.Err()
+}
@@ -0,0 +1,159 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"github.com/stretchr/testify/assert"
+)
+
+// gather collects c into a map of values keyed by metric name and label
+// values, e.g. "service_disk_saturation_queue_depth{sda}".
+func gather(t *testing.T, c prometheus.Collector) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetValue())
+			}
+
+			key := f.GetName() + "{" + strings.Join(labels, ",") + "}"
+			if m.GetGauge() != nil {
+				values[key] = m.GetGauge().GetValue()
+			} else {
+				values[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return values
+}
+
+// writeFiles writes files, keyed by their path relative to root.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectCgroup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		root    string
+		version int
+		memory  string
+	}{
+		"v1":      {root: "testdata/cgroup1", version: 1, memory: "sys/fs/cgroup/memory/memory.max"},
+		"v2":      {root: "testdata/cgroup2", version: 2, memory: "sys/fs/cgroup/memory.max"},
+		"v2 root": {root: "testdata/cgroup2-root", version: 2, memory: "sys/fs/cgroup/memory.max"},
+		"none":    {root: "testdata/host", version: 0},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			proc, err := procfs.NewFS(filepath.Join(tc.root, "proc"))
+			assert.NoError(t, err)
+
+			cg := detectCgroup(proc, tc.root)
+			assert.Equal(t, tc.version, cg.version)
+			if tc.version != 0 {
+				assert.Equal(t, filepath.Join(tc.root, tc.memory), cg.path("memory.max", "memory"))
+			}
+		})
+	}
+}
+
+func TestDetectCgroupPath(t *testing.T) {
+	t.Parallel()
+
+	// Without a cgroup namespace the host hierarchy is mounted and
+	// /proc/self/cgroup holds the path of the cgroup in it.
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/1/cgroup": "5:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n0::/\n",
+		"sys/fs/cgroup/memory/memory.usage_in_bytes":            "8589934592\n",
+		"sys/fs/cgroup/memory/docker/abc/memory.usage_in_bytes": "1073741824\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpuacct.usage":               "4000000000\n",
+	})
+	if err := os.Symlink("1", filepath.Join(root, "proc", "self")); err != nil {
+		t.Fatal(err)
+	}
+
+	proc, err := procfs.NewFS(filepath.Join(root, "proc"))
+	assert.NoError(t, err)
+
+	cg := detectCgroup(proc, root)
+	assert.Equal(t, 1, cg.version)
+	assert.Equal(t,
+		filepath.Join(root, "sys/fs/cgroup/memory/docker/abc/memory.usage_in_bytes"),
+		cg.path("memory.usage_in_bytes", "memory"))
+	// The cgroup's directory is missing from the cpu hierarchy, as when the
+	// mount is the one of the cgroup: the mount is used.
+	assert.Equal(t,
+		filepath.Join(root, "sys/fs/cgroup/cpu,cpuacct/cpuacct.usage"),
+		cg.path("cpuacct.usage", "cpuacct", "cpu,cpuacct", "cpu"))
+}
+
+func TestReadPressure(t *testing.T) {
+	t.Parallel()
+
+	totals, err := readPressure("testdata/cgroup2/sys/fs/cgroup/memory.pressure")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"some": 2, "full": 0.5}, totals)
+
+	_, err = readPressure("testdata/cgroup2/sys/fs/cgroup/memory.max")
+	assert.ErrorContains(t, err, "no total")
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestLinuxCollectorsRegister(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	opts := LinuxOpts{Namespace: "service", Root: "testdata/cgroup2"}
+
+	cpu, err := NewCPUCollector(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, cpu.Register())
+
+	memory, err := NewMemoryCollector(opts)
+	assert.NoError(t, err)
+	assert.NoError(t, memory.Register())
+
+	disk, err := NewDiskCollector(DiskOpts{LinuxOpts: opts})
+	assert.NoError(t, err)
+	assert.NoError(t, disk.Register())
+
+	network, err := NewNetworkCollector(NetworkOpts{LinuxOpts: opts})
+	assert.NoError(t, err)
+	assert.NoError(t, network.Register())
+}
@@ -0,0 +1,178 @@
+package collectors
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// MemoryCollector reads the memory usage of the cgroup, or of the host
+// outside of a cgroup, at scrape time and exposes it with USE semantics:
+//
+//	{namespace}_memory_utilization_ratio                         memory used over the memory limit
+//	{namespace}_memory_saturation_pressure_seconds_total{kind}   time "some" or "full" tasks stalled on memory
+//	{namespace}_memory_errors_total{reason="oom_kill"}           processes killed by the OOM killer
+//
+// The cgroup is the one of the process, read from /proc/self/cgroup. The
+// memory limit is the one of the cgroup, or the memory of the host when it
+// has none. At the root of a hierarchy, which has no usage files, the usage
+// is the one of the host. Pressure is the pressure stall information of the cgroup, or
+// of the host, and is not exposed by kernels without it. OOM kills are only
+// exposed inside a cgroup.
+type MemoryCollector struct {
+	proc   procfs.FS
+	cgroup cgroup
+
+	utilization *prometheus.Desc
+	pressure    *prometheus.Desc
+	errors      *prometheus.Desc
+}
+
+// NewMemoryCollector creates a MemoryCollector.
+func NewMemoryCollector(opts LinuxOpts) (*MemoryCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+
+	proc, err := procfs.NewFS(opts.procPath())
+	if err != nil {
+		return nil, err
+	}
+
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "memory", name), help, variableLabels, nil)
+	}
+
+	return &MemoryCollector{
+		proc:        proc,
+		cgroup:      detectCgroup(proc, opts.Root),
+		utilization: desc("utilization_ratio", "Ratio of memory used to the memory limit"),
+		pressure:    desc("saturation_pressure_seconds_total", "Time tasks stalled on memory in seconds", "kind"),
+		errors:      desc("errors_total", "Number of processes killed by the OOM killer", "reason"),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *MemoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.utilization
+	ch <- c.pressure
+	ch <- c.errors
+}
+
+// Collect implements prometheus.Collector.
+func (c *MemoryCollector) Collect(ch chan<- prometheus.Metric) {
+	used, limit, err := c.usage()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.utilization, err)
+
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.utilization, prometheus.GaugeValue, ratio(used, limit))
+
+	for kind, seconds := range c.stalls() {
+		ch <- prometheus.MustNewConstMetric(c.pressure, prometheus.CounterValue, seconds, kind)
+	}
+
+	if kills, ok := c.oomKills(); ok {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, kills, "oom_kill")
+	}
+}
+
+// usage returns the memory used and the memory limit in bytes.
+func (c *MemoryCollector) usage() (used, limit float64, err error) {
+	meminfo, err := c.proc.Meminfo()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total, available float64
+	if meminfo.MemTotal != nil {
+		total = float64(*meminfo.MemTotal) * 1024
+	}
+	if meminfo.MemAvailable != nil {
+		available = float64(*meminfo.MemAvailable) * 1024
+	}
+
+	var usagePath, limitPath string
+	switch c.cgroup.version {
+	case 2:
+		usagePath, limitPath = c.cgroup.path("memory.current"), c.cgroup.path("memory.max")
+	case 1:
+		usagePath, limitPath = c.cgroup.path("memory.usage_in_bytes", "memory"), c.cgroup.path("memory.limit_in_bytes", "memory")
+	default:
+		return total - available, total, nil
+	}
+
+	// The root of a hierarchy has no usage files: the cgroup is the host.
+	usage, _, err := readUint(usagePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return total - available, total, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Unlimited cgroups have a limit of "max" on v2, and of a page-aligned
+	// maximum on v1: both mean the memory of the host.
+	limit = total
+	if l, ok, err := readUint(limitPath); err == nil && ok && (total == 0 || float64(l) < total) {
+		limit = float64(l)
+	}
+
+	return float64(usage), limit, nil
+}
+
+// stalls returns the total stall time of the "some" and "full" kinds.
+func (c *MemoryCollector) stalls() map[string]float64 {
+	if c.cgroup.version == 2 {
+		if totals, err := readPressure(c.cgroup.path("memory.pressure")); err == nil {
+			return totals
+		}
+	}
+
+	psi, err := c.proc.PSIStatsForResource("memory")
+	if err != nil {
+		return nil
+	}
+
+	totals := map[string]float64{}
+	if psi.Some != nil {
+		totals["some"] = float64(psi.Some.Total) / 1e6
+	}
+	if psi.Full != nil {
+		totals["full"] = float64(psi.Full.Total) / 1e6
+	}
+
+	return totals
+}
+
+func (c *MemoryCollector) oomKills() (float64, bool) {
+	var events map[string]uint64
+	var err error
+	switch c.cgroup.version {
+	case 2:
+		events, err = readKeyed(c.cgroup.path("memory.events"))
+	case 1:
+		events, err = readKeyed(c.cgroup.path("memory.oom_control", "memory"))
+	default:
+		return 0, false
+	}
+	if err != nil {
+		return 0, false
+	}
+
+	kills, ok := events["oom_kill"]
+
+	return float64(kills), ok
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *MemoryCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
@@ -0,0 +1,63 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCollector(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		root string
+		want map[string]float64
+	}{
+		"cgroup v2": {
+			root: "testdata/cgroup2",
+			want: map[string]float64{
+				"service_memory_utilization_ratio{}":                     0.5,
+				"service_memory_saturation_pressure_seconds_total{full}": 0.5,
+				"service_memory_saturation_pressure_seconds_total{some}": 2,
+				"service_memory_errors_total{oom_kill}":                  2,
+			},
+		},
+		"cgroup v1 without a limit": {
+			root: "testdata/cgroup1",
+			want: map[string]float64{
+				"service_memory_utilization_ratio{}":                     0.0625,
+				"service_memory_saturation_pressure_seconds_total{full}": 1,
+				"service_memory_saturation_pressure_seconds_total{some}": 3,
+				"service_memory_errors_total{oom_kill}":                  1,
+			},
+		},
+		"root of a cgroup v2 hierarchy": {
+			root: "testdata/cgroup2-root",
+			want: map[string]float64{
+				"service_memory_utilization_ratio{}":                     0.25,
+				"service_memory_saturation_pressure_seconds_total{full}": 1,
+				"service_memory_saturation_pressure_seconds_total{some}": 3,
+			},
+		},
+		"host": {
+			root: "testdata/host",
+			want: map[string]float64{
+				"service_memory_utilization_ratio{}":                     0.25,
+				"service_memory_saturation_pressure_seconds_total{full}": 1,
+				"service_memory_saturation_pressure_seconds_total{some}": 3,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewMemoryCollector(LinuxOpts{Namespace: "service", Root: tc.root})
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.want, gather(t, c))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package collectors
+
+import (
+	"path/filepath"
+	"regexp"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// NetworkCollector reads /proc/net/dev at scrape time and exposes the
+// network interfaces with USE semantics, labelled by device and direction,
+// "receive" or "transmit":
+//
+//	{namespace}_network_throughput_bytes_total{device,direction}  bytes transferred
+//	{namespace}_network_capacity_bytes_per_second{device}         link speed, in each direction
+//	{namespace}_network_saturation_drops_total{device,direction}  packets dropped
+//	{namespace}_network_errors_total{device,direction}            packets that failed
+//
+// Capacity is only exposed for interfaces whose link speed is known. Bytes
+// are cumulative, so any number of scrapers get the utilization over their
+// own window with PromQL:
+//
+//	rate({namespace}_network_throughput_bytes_total[5m])
+//	/ ignoring (direction) group_left
+//	{namespace}_network_capacity_bytes_per_second
+//
+// Inside a container with its own network namespace, the interfaces are the
+// ones of the container.
+type NetworkCollector struct {
+	proc    procfs.FS
+	sys     string
+	ignored *regexp.Regexp
+
+	throughput *prometheus.Desc
+	capacity   *prometheus.Desc
+	drops      *prometheus.Desc
+	errors     *prometheus.Desc
+}
+
+// NetworkOpts is the options to create a NetworkCollector.
+type NetworkOpts struct {
+	LinuxOpts
+	// IgnoredDevices is a regular expression matching the interfaces not to
+	// expose. If not specified, defaults to the loopback interface.
+	IgnoredDevices string
+}
+
+// NewNetworkCollector creates a NetworkCollector.
+func NewNetworkCollector(opts NetworkOpts) (*NetworkCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+	opts.LinuxOpts = opts.LinuxOpts.withDefaults()
+
+	if opts.IgnoredDevices == "" {
+		opts.IgnoredDevices = `^lo$`
+	}
+	ignored, err := regexp.Compile(opts.IgnoredDevices)
+	if err != nil {
+		return nil, err
+	}
+
+	proc, err := procfs.NewFS(opts.procPath())
+	if err != nil {
+		return nil, err
+	}
+
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "network", name), help, variableLabels, nil)
+	}
+
+	return &NetworkCollector{
+		proc:       proc,
+		sys:        opts.sysPath(),
+		ignored:    ignored,
+		throughput: desc("throughput_bytes_total", "Number of bytes transferred", "device", "direction"),
+		capacity:   desc("capacity_bytes_per_second", "Link speed in bytes per second, in each direction", "device"),
+		drops:      desc("saturation_drops_total", "Number of packets dropped", "device", "direction"),
+		errors:     desc("errors_total", "Number of packets that failed", "device", "direction"),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *NetworkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.throughput
+	ch <- c.capacity
+	ch <- c.drops
+	ch <- c.errors
+}
+
+// Collect implements prometheus.Collector.
+func (c *NetworkCollector) Collect(ch chan<- prometheus.Metric) {
+	devices, err := c.proc.NetDev()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.throughput, err)
+
+		return
+	}
+
+	for name, d := range devices {
+		if c.ignored.MatchString(name) {
+			continue
+		}
+
+		if speed, ok := c.speed(name); ok {
+			ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, speed, name)
+		}
+
+		for _, direction := range []struct {
+			name                 string
+			bytes, drops, errors uint64
+		}{
+			{"receive", d.RxBytes, d.RxDropped, d.RxErrors},
+			{"transmit", d.TxBytes, d.TxDropped, d.TxErrors},
+		} {
+			ch <- prometheus.MustNewConstMetric(c.throughput, prometheus.CounterValue, float64(direction.bytes), name, direction.name)
+			ch <- prometheus.MustNewConstMetric(c.drops, prometheus.CounterValue, float64(direction.drops), name, direction.name)
+			ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(direction.errors), name, direction.name)
+		}
+	}
+}
+
+// speed returns the link speed of device in bytes per second.
+func (c *NetworkCollector) speed(device string) (float64, bool) {
+	mbps, err := readInt(filepath.Join(c.sys, "class", "net", device, "speed"))
+	if err != nil || mbps <= 0 {
+		return 0, false
+	}
+
+	return float64(mbps) * 1e6 / 8, true
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *NetworkCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
@@ -0,0 +1,50 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkCollector(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewNetworkCollector(NetworkOpts{LinuxOpts: LinuxOpts{Namespace: "service", Root: "testdata/host"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{
+		"service_network_throughput_bytes_total{eth0,receive}":  5000000,
+		"service_network_throughput_bytes_total{eth0,transmit}": 2000000,
+		"service_network_capacity_bytes_per_second{eth0}":       125000000,
+		"service_network_saturation_drops_total{eth0,receive}":  3,
+		"service_network_saturation_drops_total{eth0,transmit}": 4,
+		"service_network_errors_total{eth0,receive}":            2,
+		"service_network_errors_total{eth0,transmit}":           1,
+	}, gather(t, c))
+}
+
+func TestNetworkCollectorCapacity(t *testing.T) {
+	t.Parallel()
+
+	const header = "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n"
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/net/dev": header +
+			"  eth0: 5000000 0 0 0 0 0 0 0 2000000 0 0 0 0 0 0 0\n" +
+			"  eth1: 5000000 0 0 0 0 0 0 0 2000000 0 0 0 0 0 0 0\n",
+		"sys/class/net/eth0/speed": "1000\n",
+		"sys/class/net/eth1/speed": "-1\n",
+	})
+
+	c, err := NewNetworkCollector(NetworkOpts{LinuxOpts: LinuxOpts{Namespace: "service", Root: root}})
+	assert.NoError(t, err)
+
+	values := gather(t, c)
+
+	// 1Gb/s is 125MB/s in each direction.
+	assert.Equal(t, 125e6, values["service_network_capacity_bytes_per_second{eth0}"])
+	assert.NotContains(t, values, "service_network_capacity_bytes_per_second{eth1}")
+	assert.Equal(t, 5e6, values["service_network_throughput_bytes_total{eth1,receive}"])
+}
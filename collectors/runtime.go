@@ -0,0 +1,273 @@
+// Package collectors are prometheus.Collectors that read resources at scrape
+// time and expose them with USE semantics.
+package collectors
+
+import (
+	"math"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	"sync"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// The runtime/metrics read by the RuntimeCollector.
+const (
+	cpuTotal          = "/cpu/classes/total:cpu-seconds"
+	cpuIdle           = "/cpu/classes/idle:cpu-seconds"
+	cpuGC             = "/cpu/classes/gc/total:cpu-seconds"
+	schedLatencies    = "/sched/latencies:seconds"
+	runnableGoroutine = "/sched/goroutines/runnable:goroutines"
+	memoryTotal       = "/memory/classes/total:bytes"
+	memoryReleased    = "/memory/classes/heap/released:bytes"
+	memoryLimit       = "/gc/gomemlimit:bytes"
+	gcCycles          = "/gc/cycles/total:gc-cycles"
+	gcForced          = "/gc/cycles/forced:gc-cycles"
+)
+
+// defaultSchedLatencyBuckets are the buckets of the scheduler latency
+// histogram, from a microsecond to a second.
+var defaultSchedLatencyBuckets = []float64{1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1, 1}
+
+// RuntimeCollector reads runtime/metrics at scrape time and exposes the Go
+// runtime with USE semantics:
+//
+//	{namespace}_runtime_cpu_seconds_total{resource="cpu"}     CPU time used by the process
+//	{namespace}_runtime_cpu_seconds_total{resource="gc_cpu"}  CPU time used by the GC
+//	{namespace}_runtime_cpu_capacity_cores                    GOMAXPROCS, the CPUs goroutines run on
+//	{namespace}_runtime_saturation_sched_latency_seconds      histogram of the time goroutines waited to run
+//	{namespace}_runtime_saturation_runnable_goroutines        goroutines waiting to run
+//	{namespace}_runtime_saturation_memory_limit_ratio         memory not released to the OS over GOMEMLIMIT
+//	{namespace}_runtime_errors_total{reason="gc_cycle"}       completed GC cycles
+//	{namespace}_runtime_errors_total{reason="forced_gc"}      GC cycles forced by the application
+//
+// CPU time and scheduler latency are cumulative, so any number of scrapers
+// get the utilization over their own window with PromQL:
+//
+//	rate({namespace}_runtime_cpu_seconds_total{resource="cpu"}[5m]) / {namespace}_runtime_cpu_capacity_cores
+//
+// Process CPU time is read from procfs, as the runtime only accounts CPU
+// time at GC. Where procfs is not available, it falls back to the runtime's
+// busy CPU time, which lags until the next GC. Metrics the runtime does not
+// support, such as runnable goroutines on older Go versions, are not
+// exposed. The memory limit ratio leaves out the heap released to the OS, as
+// GOMEMLIMIT does, and is 0 when GOMEMLIMIT is not set. None of the names
+// overlap the ones of the standard Go collector, so both can be registered
+// with the same registry.
+type RuntimeCollector struct {
+	cpu          *prometheus.Desc
+	cpuCapacity  *prometheus.Desc
+	schedLatency *prometheus.Desc
+	runnable     *prometheus.Desc
+	memoryLimit  *prometheus.Desc
+	errors       *prometheus.Desc
+	supported    map[string]bool
+	buckets      []float64
+	processCPU   func() (float64, error)
+	opts         RuntimeOpts
+
+	// mu guards samples, whose histograms Read reuses.
+	mu      sync.Mutex
+	samples []runtimemetrics.Sample
+}
+
+// RuntimeOpts is the options to create a RuntimeCollector.
+type RuntimeOpts struct {
+	// Namespace prefixes the metric names. It can't be "go", the namespace of
+	// the standard Go collector.
+	Namespace string `validate:"required,ne=go"`
+	// SchedLatencyBuckets defines the buckets of the scheduler latency
+	// histogram. If not specified, defaults to powers of ten from 1µs to 1s.
+	SchedLatencyBuckets []float64
+}
+
+// NewRuntimeCollector creates a RuntimeCollector.
+func NewRuntimeCollector(opts RuntimeOpts) (*RuntimeCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	buckets := opts.SchedLatencyBuckets
+	if buckets == nil {
+		buckets = defaultSchedLatencyBuckets
+	}
+
+	supported := map[string]bool{}
+	for _, d := range runtimemetrics.All() {
+		supported[d.Name] = true
+	}
+
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "runtime", name), help, variableLabels, nil)
+	}
+
+	var samples []runtimemetrics.Sample
+	for _, name := range []string{
+		cpuTotal, cpuIdle, cpuGC, schedLatencies, runnableGoroutine, memoryTotal, memoryReleased, memoryLimit, gcCycles, gcForced,
+	} {
+		if supported[name] {
+			samples = append(samples, runtimemetrics.Sample{Name: name})
+		}
+	}
+
+	return &RuntimeCollector{
+		cpu:          desc("cpu_seconds_total", "CPU time used in seconds, by resource", "resource"),
+		cpuCapacity:  desc("cpu_capacity_cores", "Number of CPUs goroutines run on, GOMAXPROCS"),
+		schedLatency: desc("saturation_sched_latency_seconds", "Time goroutines waited to run in seconds"),
+		runnable:     desc("saturation_runnable_goroutines", "Number of goroutines waiting to run"),
+		memoryLimit:  desc("saturation_memory_limit_ratio", "Ratio of memory not released to the OS to GOMEMLIMIT"),
+		errors:       desc("errors_total", "Number of GC cycles", "reason"),
+		supported:    supported,
+		buckets:      buckets,
+		processCPU:   processCPU,
+		opts:         opts,
+		samples:      samples,
+	}, nil
+}
+
+// Opts returns the options the RuntimeCollector was created with.
+func (c *RuntimeCollector) Opts() RuntimeOpts {
+	return c.opts
+}
+
+// processCPU returns the user and system CPU time of the process.
+func processCPU() (float64, error) {
+	p, err := procfs.Self()
+	if err != nil {
+		return 0, err
+	}
+
+	stat, err := p.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.CPUTime(), nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *RuntimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpu
+	ch <- c.cpuCapacity
+	ch <- c.schedLatency
+	if c.supported[runnableGoroutine] {
+		ch <- c.runnable
+	}
+	ch <- c.memoryLimit
+	ch <- c.errors
+}
+
+// Collect implements prometheus.Collector.
+func (c *RuntimeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	runtimemetrics.Read(c.samples)
+
+	current := make(map[string]runtimemetrics.Value, len(c.samples))
+	for _, s := range c.samples {
+		if s.Value.Kind() != runtimemetrics.KindBad {
+			current[s.Name] = s.Value
+		}
+	}
+
+	if cpu, err := c.processCPU(); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpu, "cpu")
+	} else if total, ok := current[cpuTotal]; ok {
+		if idle, ok := current[cpuIdle]; ok {
+			ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, total.Float64()-idle.Float64(), "cpu")
+		}
+	}
+	if v, ok := current[cpuGC]; ok {
+		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, v.Float64(), "gc_cpu")
+	}
+	ch <- prometheus.MustNewConstMetric(c.cpuCapacity, prometheus.GaugeValue, float64(runtime.GOMAXPROCS(0)))
+
+	if v, ok := current[schedLatencies]; ok {
+		h := v.Float64Histogram()
+		count, sum, buckets := rebucket(h.Counts, h.Buckets, c.buckets)
+		ch <- prometheus.MustNewConstHistogram(c.schedLatency, count, sum, buckets)
+	}
+
+	if v, ok := current[runnableGoroutine]; ok {
+		ch <- prometheus.MustNewConstMetric(c.runnable, prometheus.GaugeValue, float64(v.Uint64()))
+	}
+
+	if v, ok := current[memoryTotal]; ok {
+		used := float64(v.Uint64())
+		if r, ok := current[memoryReleased]; ok {
+			used -= float64(r.Uint64())
+		}
+
+		var limit float64
+		if l, ok := current[memoryLimit]; ok && l.Uint64() != math.MaxInt64 {
+			limit = float64(l.Uint64())
+		}
+		ch <- prometheus.MustNewConstMetric(c.memoryLimit, prometheus.GaugeValue, ratio(used, limit))
+	}
+
+	if v, ok := current[gcCycles]; ok {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(v.Uint64()), "gc_cycle")
+	}
+	if v, ok := current[gcForced]; ok {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(v.Uint64()), "forced_gc")
+	}
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *RuntimeCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
+
+func ratio(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+
+	return part / whole
+}
+
+// rebucket turns the counts of a runtime/metrics histogram into the count,
+// sum and cumulative bucket counts of a Prometheus histogram with buckets.
+// A runtime bucket is counted in the first bucket holding its upper bound,
+// so latencies are overestimated rather than hidden. The runtime keeps no
+// sum, so it is estimated from the middle of every bucket, or its finite
+// bound for unbounded ones.
+func rebucket(counts []uint64, runtimeBuckets, buckets []float64) (uint64, float64, map[float64]uint64) {
+	cumulative := make(map[float64]uint64, len(buckets))
+	for _, b := range buckets {
+		cumulative[b] = 0
+	}
+
+	var count uint64
+	var sum float64
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+
+		lower, upper := runtimeBuckets[i], runtimeBuckets[i+1]
+		count += n
+
+		switch {
+		case math.IsInf(lower, -1):
+			sum += float64(n) * math.Max(upper, 0)
+		case math.IsInf(upper, 1):
+			sum += float64(n) * lower
+		default:
+			sum += float64(n) * (lower + upper) / 2
+		}
+
+		for _, b := range buckets {
+			if upper <= b {
+				cumulative[b] += n
+			}
+		}
+	}
+
+	return count, sum, cumulative
+}
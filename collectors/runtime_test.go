@@ -0,0 +1,237 @@
+package collectors
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"runtime/debug"
+	runtimemetrics "runtime/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRuntimeCollector(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts RuntimeOpts
+		err  string
+	}{
+		"valid": {
+			opts: RuntimeOpts{Namespace: "service"},
+		},
+		"missing namespace": {
+			opts: RuntimeOpts{},
+			err:  "RuntimeOpts.Namespace",
+		},
+		"go namespace": {
+			opts: RuntimeOpts{Namespace: "go"},
+			err:  "RuntimeOpts.Namespace",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewRuntimeCollector(tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// gatherRuntime gathers c next to the standard Go collector and returns the
+// values of the service_runtime_ metrics, keyed by name and label values.
+func gatherRuntime(t *testing.T, reg *prometheus.Registry) (map[string]float64, map[string]*dto.Histogram) {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	histograms := map[string]*dto.Histogram{}
+	for _, f := range families {
+		if !strings.HasPrefix(f.GetName(), "service_runtime_") {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			key := f.GetName()
+			for _, l := range m.GetLabel() {
+				key += "/" + l.GetValue()
+			}
+			switch {
+			case m.GetHistogram() != nil:
+				histograms[key] = m.GetHistogram()
+			case m.GetGauge() != nil:
+				values[key] = m.GetGauge().GetValue()
+			default:
+				values[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return values, histograms
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewRuntimeCollector(RuntimeOpts{Namespace: "service"})
+	assert.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+	assert.NoError(t, reg.Register(collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll))))
+
+	runtime.GC()
+
+	values, histograms := gatherRuntime(t, reg)
+
+	assert.Greater(t, values["service_runtime_cpu_seconds_total/cpu"], 0.0)
+	assert.Contains(t, values, "service_runtime_cpu_seconds_total/gc_cpu")
+	assert.Equal(t, float64(runtime.GOMAXPROCS(0)), values["service_runtime_cpu_capacity_cores"])
+	assert.Contains(t, histograms, "service_runtime_saturation_sched_latency_seconds")
+	assert.Contains(t, values, "service_runtime_saturation_memory_limit_ratio")
+	assert.GreaterOrEqual(t, values["service_runtime_errors_total/gc_cycle"], 1.0)
+	assert.GreaterOrEqual(t, values["service_runtime_errors_total/forced_gc"], 1.0)
+
+	lint, err := testutil.CollectAndLint(c)
+	assert.NoError(t, err)
+	assert.Empty(t, lint)
+}
+
+//nolint:paralleltest // sets the memory limit of the process
+func TestRuntimeCollectorMemoryLimit(t *testing.T) {
+	c, err := NewRuntimeCollector(RuntimeOpts{Namespace: "service"})
+	assert.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	const limit = 1 << 40
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(limit))
+
+	// Release the heap to the OS so released memory is large enough to tell
+	// the two ratios apart.
+	debug.FreeOSMemory()
+
+	samples := []runtimemetrics.Sample{{Name: memoryTotal}, {Name: memoryReleased}}
+	runtimemetrics.Read(samples)
+	total, released := float64(samples[0].Value.Uint64()), float64(samples[1].Value.Uint64())
+
+	values, _ := gatherRuntime(t, reg)
+
+	assert.InEpsilon(t, (total-released)/limit, values["service_runtime_saturation_memory_limit_ratio"], 0.1)
+	assert.Less(t, values["service_runtime_saturation_memory_limit_ratio"], total/limit)
+}
+
+func TestRuntimeCollectorCumulative(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewRuntimeCollector(RuntimeOpts{Namespace: "service"})
+	assert.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	first, firstHistograms := gatherRuntime(t, reg)
+
+	// Busy without allocating, so without a GC: CPU time still grows.
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for n := 0; time.Now().Before(deadline); n++ {
+		_ = n * n
+	}
+
+	// Another scraper gathering in between takes nothing from the next one.
+	_, _ = gatherRuntime(t, reg)
+	second, secondHistograms := gatherRuntime(t, reg)
+
+	assert.Greater(t, second["service_runtime_cpu_seconds_total/cpu"], first["service_runtime_cpu_seconds_total/cpu"])
+	assert.GreaterOrEqual(t,
+		secondHistograms["service_runtime_saturation_sched_latency_seconds"].GetSampleCount(),
+		firstHistograms["service_runtime_saturation_sched_latency_seconds"].GetSampleCount())
+}
+
+func TestRuntimeCollectorFallback(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewRuntimeCollector(RuntimeOpts{Namespace: "service"})
+	assert.NoError(t, err)
+	c.processCPU = func() (float64, error) { return 0, errors.New("no procfs") }
+
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	values, _ := gatherRuntime(t, reg)
+	assert.Contains(t, values, "service_runtime_cpu_seconds_total/cpu")
+}
+
+func TestRebucket(t *testing.T) {
+	t.Parallel()
+
+	runtimeBuckets := []float64{math.Inf(-1), 0, 0.0005, 0.002, 0.05, math.Inf(1)}
+	buckets := []float64{0.001, 0.01, 0.1}
+
+	tests := map[string]struct {
+		counts     []uint64
+		count      uint64
+		sum        float64
+		cumulative map[float64]uint64
+	}{
+		"empty": {
+			counts:     []uint64{0, 0, 0, 0, 0},
+			cumulative: map[float64]uint64{0.001: 0, 0.01: 0, 0.1: 0},
+		},
+		"spread": {
+			counts:     []uint64{0, 4, 2, 1, 0},
+			count:      7,
+			sum:        4*0.00025 + 2*0.00125 + 0.026,
+			cumulative: map[float64]uint64{0.001: 4, 0.01: 6, 0.1: 7},
+		},
+		"unbounded tail": {
+			counts:     []uint64{0, 0, 0, 0, 3},
+			count:      3,
+			sum:        3 * 0.05,
+			cumulative: map[float64]uint64{0.001: 0, 0.01: 0, 0.1: 0},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			count, sum, cumulative := rebucket(tc.counts, runtimeBuckets, buckets)
+			assert.Equal(t, tc.count, count)
+			assert.InDelta(t, tc.sum, sum, 1e-12)
+			assert.Equal(t, tc.cumulative, cumulative)
+		})
+	}
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestRuntimeCollectorRegister(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	c, err := NewRuntimeCollector(RuntimeOpts{Namespace: "service"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Register())
+}
@@ -0,0 +1,613 @@
+// Package config builds metrics and strategies from a declarative YAML or JSON
+// document so operators can add or tweak them without recompiling.
+//
+//	counters:
+//	  jobs:
+//	    namespace: worker
+//	    name: jobs_total
+//	    help: Number of jobs processed
+//	    labels: [queue]
+//	red:
+//	  api:
+//	    namespace: service
+//	    requests:
+//	      type: http
+//	      labels: [path, verb]
+//	    errors:
+//	      labels: [error]
+//	    duration:
+//	      labels: [path]
+//	      buckets: [0.05, 0.1, 0.25, 0.5, 1]
+//
+// Every metric and strategy is built through the constructors of the metrics
+// and strategy packages, so the same validation rules apply.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+	"github.com/rabellamy/promstrap/strategy"
+	"gopkg.in/yaml.v3"
+)
+
+// Set holds the metrics and strategies declared in a document, keyed by the
+// name they were declared under.
+type Set struct {
+	Counters      map[string]*prometheus.CounterVec
+	Gauges        map[string]*prometheus.GaugeVec
+	Histograms    map[string]*prometheus.HistogramVec
+	Summaries     map[string]*prometheus.SummaryVec
+	Distributions map[string]*strategy.Distribution
+	RED           map[string]*strategy.RED
+	USE           map[string]*strategy.USE
+	FGS           map[string]*strategy.FourGoldenSignals
+}
+
+// Error is a problem found in a document, positioned at the offending node.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// LoadFile reads and builds the document at path.
+func LoadFile(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(path, data)
+}
+
+// Load builds the YAML or JSON document in data. file is only used to
+// position errors.
+func Load(file string, data []byte) (*Set, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	set := &Set{
+		Counters:      map[string]*prometheus.CounterVec{},
+		Gauges:        map[string]*prometheus.GaugeVec{},
+		Histograms:    map[string]*prometheus.HistogramVec{},
+		Summaries:     map[string]*prometheus.SummaryVec{},
+		Distributions: map[string]*strategy.Distribution{},
+		RED:           map[string]*strategy.RED{},
+		USE:           map[string]*strategy.USE{},
+		FGS:           map[string]*strategy.FourGoldenSignals{},
+	}
+
+	// An empty document declares nothing.
+	if len(doc.Content) == 0 {
+		return set, nil
+	}
+
+	l := loader{file: file, set: set}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, l.errorf(root, "document must be a mapping of sections")
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		build, ok := l.sections()[key.Value]
+		if !ok {
+			return nil, l.errorf(key, "unknown section %q", key.Value)
+		}
+		if seen[key.Value] {
+			return nil, l.errorf(key, "section %q is already declared", key.Value)
+		}
+		seen[key.Value] = true
+
+		if err := l.entries(value, build); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+// Register registers every metric and strategy in the Set with the Prometheus
+// DefaultRegisterer, in name order.
+func (s *Set) Register() error {
+	var collectors []prometheus.Collector
+	for _, name := range sortedKeys(s.Counters) {
+		collectors = append(collectors, s.Counters[name])
+	}
+	for _, name := range sortedKeys(s.Gauges) {
+		collectors = append(collectors, s.Gauges[name])
+	}
+	for _, name := range sortedKeys(s.Histograms) {
+		collectors = append(collectors, s.Histograms[name])
+	}
+	for _, name := range sortedKeys(s.Summaries) {
+		collectors = append(collectors, s.Summaries[name])
+	}
+	if err := metrics.RegisterCollectors(collectors...); err != nil {
+		return err
+	}
+
+	var strategies []strategy.Strategy
+	for _, name := range sortedKeys(s.Distributions) {
+		strategies = append(strategies, s.Distributions[name])
+	}
+	for _, name := range sortedKeys(s.RED) {
+		strategies = append(strategies, s.RED[name])
+	}
+	for _, name := range sortedKeys(s.USE) {
+		strategies = append(strategies, s.USE[name])
+	}
+	for _, name := range sortedKeys(s.FGS) {
+		strategies = append(strategies, s.FGS[name])
+	}
+	for _, st := range strategies {
+		if err := st.Register(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+type loader struct {
+	file string
+	set  *Set
+}
+
+// builder decodes a single named entry of a section and builds it.
+type builder func(name string, node *yaml.Node) error
+
+func (l loader) sections() map[string]builder {
+	return map[string]builder{
+		"counters":      l.counter,
+		"gauges":        l.gauge,
+		"histograms":    l.histogram,
+		"summaries":     l.summary,
+		"distributions": l.distribution,
+		"red":           l.red,
+		"use":           l.use,
+		"fgs":           l.fgs,
+	}
+}
+
+func (l loader) entries(section *yaml.Node, build builder) error {
+	if section.Kind != yaml.MappingNode {
+		return l.errorf(section, "section must be a mapping of names")
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < len(section.Content); i += 2 {
+		key, value := section.Content[i], section.Content[i+1]
+		if seen[key.Value] {
+			return l.errorf(key, "%q is already declared", key.Value)
+		}
+		seen[key.Value] = true
+
+		if err := build(key.Value, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decode strictly decodes node into out, rejecting keys out does not declare.
+func (l loader) decode(node *yaml.Node, out interface{}) error {
+	if err := l.checkFields(node, reflect.TypeOf(out).Elem()); err != nil {
+		return err
+	}
+
+	if err := node.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return l.errorAtLine(node, typeErr.Errors[0])
+		}
+
+		return l.errorAtLine(node, err.Error())
+	}
+
+	return nil
+}
+
+// errorAtLine positions a yaml.v3 decoding message, which carries its own
+// "line N: " prefix, falling back to the position of node.
+func (l loader) errorAtLine(node *yaml.Node, msg string) error {
+	line := node.Line
+	column := node.Column
+	if _, err := fmt.Sscanf(msg, "line %d: ", &line); err == nil {
+		msg = msg[strings.Index(msg, ": ")+2:]
+		column = 0
+	}
+
+	return &Error{File: l.file, Line: line, Column: column, Err: errors.New(msg)}
+}
+
+func (l loader) checkFields(node *yaml.Node, t reflect.Type) error {
+	if t.Kind() != reflect.Struct || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		fields[name] = t.Field(i).Type
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		field, ok := fields[key.Value]
+		if !ok {
+			return l.errorf(key, "unknown field %q", key.Value)
+		}
+
+		if err := l.checkFields(value, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wrap positions an error returned by a constructor at the entry it came from.
+func (l loader) wrap(name string, node *yaml.Node, err error) error {
+	return &Error{File: l.file, Line: node.Line, Column: node.Column, Err: fmt.Errorf("%s: %w", name, err)}
+}
+
+func (l loader) errorf(node *yaml.Node, format string, args ...interface{}) error {
+	return &Error{File: l.file, Line: node.Line, Column: node.Column, Err: fmt.Errorf(format, args...)}
+}
+
+// objectives decodes summary objectives from YAML float keys as well as from
+// the string keys JSON forces on them.
+type objectives map[float64]float64
+
+func (o *objectives) UnmarshalYAML(node *yaml.Node) error {
+	var raw map[string]float64
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	*o = objectives{}
+	for k, v := range raw {
+		quantile, err := strconv.ParseFloat(k, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: objective quantile %q is not a number", node.Line, k)
+		}
+		(*o)[quantile] = v
+	}
+
+	return nil
+}
+
+func (l loader) counter(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace string   `yaml:"namespace"`
+		Name      string   `yaml:"name"`
+		Help      string   `yaml:"help"`
+		Labels    []string `yaml:"labels"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	counter, err := metrics.NewCounterWithLabels(metrics.CounterOpts{
+		Namespace: spec.Namespace,
+		Name:      spec.Name,
+		Help:      spec.Help,
+		Labels:    spec.Labels,
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.Counters[name] = counter
+
+	return nil
+}
+
+func (l loader) gauge(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace string   `yaml:"namespace"`
+		Name      string   `yaml:"name"`
+		Help      string   `yaml:"help"`
+		Labels    []string `yaml:"labels"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	gauge, err := metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+		Namespace: spec.Namespace,
+		Name:      spec.Name,
+		Help:      spec.Help,
+		Labels:    spec.Labels,
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.Gauges[name] = gauge
+
+	return nil
+}
+
+func (l loader) histogram(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace string    `yaml:"namespace"`
+		Name      string    `yaml:"name"`
+		Help      string    `yaml:"help"`
+		Labels    []string  `yaml:"labels"`
+		Buckets   []float64 `yaml:"buckets"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	histogram, err := metrics.NewHistogramWithLabels(metrics.HistogramOpts{
+		Namespace: spec.Namespace,
+		Name:      spec.Name,
+		Help:      spec.Help,
+		Labels:    spec.Labels,
+		Buckets:   spec.Buckets,
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.Histograms[name] = histogram
+
+	return nil
+}
+
+func (l loader) summary(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace  string     `yaml:"namespace"`
+		Name       string     `yaml:"name"`
+		Help       string     `yaml:"help"`
+		Labels     []string   `yaml:"labels"`
+		Objectives objectives `yaml:"objectives"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	summary, err := metrics.NewSummaryWithLabels(metrics.SummaryOpts{
+		Namespace:  spec.Namespace,
+		Name:       spec.Name,
+		Help:       spec.Help,
+		Labels:     spec.Labels,
+		Objectives: spec.Objectives,
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.Summaries[name] = summary
+
+	return nil
+}
+
+func (l loader) distribution(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace  string     `yaml:"namespace"`
+		Name       string     `yaml:"name"`
+		Help       string     `yaml:"help"`
+		Labels     []string   `yaml:"labels"`
+		Buckets    []float64  `yaml:"buckets"`
+		Objectives objectives `yaml:"objectives"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	distribution, err := strategy.NewDistribution(strategy.DistributionOpts{
+		Namespace:  spec.Namespace,
+		Name:       spec.Name,
+		Help:       spec.Help,
+		Labels:     spec.Labels,
+		Buckets:    spec.Buckets,
+		Objectives: spec.Objectives,
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.Distributions[name] = distribution
+
+	return nil
+}
+
+func (l loader) red(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace string `yaml:"namespace"`
+		Requests  struct {
+			Name   string   `yaml:"name"`
+			Type   string   `yaml:"type"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"requests"`
+		Errors struct {
+			Name   string   `yaml:"name"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"errors"`
+		Duration struct {
+			Name       string     `yaml:"name"`
+			Labels     []string   `yaml:"labels"`
+			Buckets    []float64  `yaml:"buckets"`
+			Objectives objectives `yaml:"objectives"`
+		} `yaml:"duration"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: spec.Namespace,
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestName:   spec.Requests.Name,
+			RequestType:   spec.Requests.Type,
+			RequestLabels: spec.Requests.Labels,
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorName:   spec.Errors.Name,
+			ErrorLabels: spec.Errors.Labels,
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationName:   spec.Duration.Name,
+			DurationLabels: spec.Duration.Labels,
+			Buckets:        spec.Duration.Buckets,
+			Objectives:     spec.Duration.Objectives,
+		},
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.RED[name] = red
+
+	return nil
+}
+
+func (l loader) use(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace   string `yaml:"namespace"`
+		Utilization struct {
+			Name   string   `yaml:"name"`
+			Help   string   `yaml:"help"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"utilization"`
+		Saturation struct {
+			Name   string   `yaml:"name"`
+			Help   string   `yaml:"help"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"saturation"`
+		Errors struct {
+			Name   string   `yaml:"name"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"errors"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	use, err := strategy.NewUSE(strategy.USEOpts{
+		Namespace: spec.Namespace,
+		UtilizationOpt: strategy.USEUtilizationOpt{
+			UtilizationName:   spec.Utilization.Name,
+			UtilizationHelp:   spec.Utilization.Help,
+			UtilizationLabels: spec.Utilization.Labels,
+		},
+		SaturationOpt: strategy.USESaturationOpt{
+			SaturationName:   spec.Saturation.Name,
+			SaturationHelp:   spec.Saturation.Help,
+			SaturationLabels: spec.Saturation.Labels,
+		},
+		ErrorsOpt: strategy.USEErrorsOpt{
+			ErrorName:   spec.Errors.Name,
+			ErrorLabels: spec.Errors.Labels,
+		},
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.USE[name] = use
+
+	return nil
+}
+
+func (l loader) fgs(name string, node *yaml.Node) error {
+	var spec struct {
+		Namespace string `yaml:"namespace"`
+		Latency   struct {
+			Name       string     `yaml:"name"`
+			Type       string     `yaml:"type"`
+			Help       string     `yaml:"help"`
+			Labels     []string   `yaml:"labels"`
+			Buckets    []float64  `yaml:"buckets"`
+			Objectives objectives `yaml:"objectives"`
+		} `yaml:"latency"`
+		Traffic struct {
+			Name   string   `yaml:"name"`
+			Type   string   `yaml:"type"`
+			Help   string   `yaml:"help"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"traffic"`
+		Errors struct {
+			Name   string   `yaml:"name"`
+			Help   string   `yaml:"help"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"errors"`
+		Saturation struct {
+			Name   string   `yaml:"name"`
+			Help   string   `yaml:"help"`
+			Labels []string `yaml:"labels"`
+		} `yaml:"saturation"`
+	}
+	if err := l.decode(node, &spec); err != nil {
+		return err
+	}
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: spec.Namespace,
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   spec.Latency.Name,
+			LatencyType:   spec.Latency.Type,
+			LatencyHelp:   spec.Latency.Help,
+			LatencyLabels: spec.Latency.Labels,
+			Buckets:       spec.Latency.Buckets,
+			Objectives:    spec.Latency.Objectives,
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   spec.Traffic.Name,
+			TrafficType:   spec.Traffic.Type,
+			TrafficHelp:   spec.Traffic.Help,
+			TrafficLabels: spec.Traffic.Labels,
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorName:   spec.Errors.Name,
+			ErrorHelp:   spec.Errors.Help,
+			ErrorLabels: spec.Errors.Labels,
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   spec.Saturation.Name,
+			SaturationHelp:   spec.Saturation.Help,
+			SaturationLabels: spec.Saturation.Labels,
+		},
+	})
+	if err != nil {
+		return l.wrap(name, node, err)
+	}
+	l.set.FGS[name] = fgs
+
+	return nil
+}
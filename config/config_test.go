@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	set, err := LoadFile("testdata/metrics.yaml")
+	assert.NoError(t, err)
+
+	assert.Contains(t, set.Counters, "jobs")
+	assert.Contains(t, set.Gauges, "queue_length")
+	assert.Contains(t, set.Histograms, "job_duration")
+	assert.Contains(t, set.Summaries, "job_size")
+	assert.Contains(t, set.Distributions, "payload")
+	assert.Contains(t, set.USE, "memory")
+	assert.Contains(t, set.FGS, "frontend")
+
+	assert.Equal(t, "http_requests_total", set.RED["api"].RequestMetricName())
+	assert.Equal(t, "memory_utilization_ratio", set.USE["memory"].UtilizationMetricName())
+	assert.Equal(t, "http_server_requests_total", set.FGS["frontend"].TrafficMetricName())
+
+	want := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:  "worker",
+		Name:       "job_size_bytes",
+		Help:       "Size of jobs in bytes",
+		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001},
+	}, []string{"queue"})
+	assert.EqualExportedValues(t, *want, *set.Summaries["job_size"])
+}
+
+func TestLoadFileJSON(t *testing.T) {
+	t.Parallel()
+
+	set, err := LoadFile("testdata/metrics.json")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "grpc_requests_total", set.RED["api"].RequestMetricName())
+	assert.Equal(t, "grpc_errors_total", set.RED["api"].ErrorMetricName())
+
+	want := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:  "worker",
+		Name:       "job_size_bytes",
+		Help:       "Size of jobs in bytes",
+		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001},
+	}, []string{"queue"})
+	assert.EqualExportedValues(t, *want, *set.Summaries["job_size"])
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		doc     string
+		wantErr string
+	}{
+		"unknown section": {
+			doc:     "meters:\n  foo: {}\n",
+			wantErr: `app.yaml:1:1: unknown section "meters"`,
+		},
+		"unknown field": {
+			doc: `counters:
+  jobs:
+    namespace: worker
+    nme: jobs_total
+`,
+			wantErr: `app.yaml:4:5: unknown field "nme"`,
+		},
+		"unknown nested field": {
+			doc: `red:
+  api:
+    namespace: service
+    requests:
+      type: http
+      lables: [path]
+`,
+			wantErr: `app.yaml:6:7: unknown field "lables"`,
+		},
+		"wrong type": {
+			doc: `histograms:
+  job_duration:
+    namespace: worker
+    name: job_duration_seconds
+    help: Duration of jobs in seconds
+    labels: [queue]
+    buckets: fast
+`,
+			wantErr: "app.yaml:7: cannot unmarshal !!str `fast` into []float64",
+		},
+		"bad objective": {
+			doc: `summaries:
+  job_size:
+    objectives:
+      median: 0.05
+`,
+			wantErr: `app.yaml:4: objective quantile "median" is not a number`,
+		},
+		"duplicate name": {
+			doc: `gauges:
+  queue_length: {namespace: worker, name: queue_length, help: Queue length, labels: [queue]}
+  queue_length: {namespace: worker, name: queue_length, help: Queue length, labels: [queue]}
+`,
+			wantErr: `app.yaml:3:3: "queue_length" is already declared`,
+		},
+		"duplicate section": {
+			doc: `gauges:
+  queue_length: {namespace: worker, name: queue_length, help: Queue length, labels: [queue]}
+gauges:
+  in_flight: {namespace: worker, name: in_flight, help: In flight, labels: [queue]}
+`,
+			wantErr: `app.yaml:3:1: section "gauges" is already declared`,
+		},
+		"constructor validation": {
+			doc: `counters:
+  jobs:
+    namespace: worker
+    name: jobs_total
+    labels: [queue]
+`,
+			wantErr: "app.yaml:3:5: jobs: Key: 'CounterOpts.Help' Error:Field validation for 'Help' failed on the 'required' tag",
+		},
+		"not a mapping": {
+			doc:     "- counters\n",
+			wantErr: "app.yaml:1:1: document must be a mapping of sections",
+		},
+	}
+
+	for name, tt := range tests {
+		doc := tt.doc
+		wantErr := tt.wantErr
+
+		t.Run(name, func(t *testing.T) {
+			_, err := Load("app.yaml", []byte(doc))
+			assert.EqualError(t, err, wantErr)
+
+			var cfgErr *Error
+			assert.True(t, errors.As(err, &cfgErr))
+		})
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	t.Parallel()
+
+	set, err := Load("empty.yaml", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, set.Counters)
+}
@@ -0,0 +1,392 @@
+// Package dashboard generates Grafana dashboards from strategies. Every query
+// is built from the metric name accessors of the strategies, so dashboards
+// never drift from the code that records the metrics.
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Opts is the options to generate a dashboard.
+type Opts struct {
+	Title string `validate:"required"`
+	// UID is the unique identifier of the dashboard. If not specified, Grafana
+	// generates one on import.
+	UID string
+	// Quantiles are the latency quantiles plotted from histograms. If not
+	// specified, defaults to 0.5, 0.9 and 0.99.
+	Quantiles []float64
+}
+
+func (o Opts) quantiles() []float64 {
+	if o.Quantiles != nil {
+		return o.Quantiles
+	}
+
+	return []float64{0.5, 0.9, 0.99}
+}
+
+// Dashboard is the subset of the Grafana dashboard JSON model promstrap
+// generates.
+type Dashboard struct {
+	UID           string     `json:"uid,omitempty"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Time          Time       `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+// Time is the default time range of a dashboard.
+type Time struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating holds the template variables of a dashboard.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard template variable.
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label,omitempty"`
+	Type       string      `json:"type"`
+	Query      string      `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	IncludeAll bool        `json:"includeAll"`
+	Multi      bool        `json:"multi"`
+	AllValue   string      `json:"allValue,omitempty"`
+}
+
+// Datasource references a Grafana data source.
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// Panel is a row or a time series panel.
+type Panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	GridPos     GridPos      `json:"gridPos"`
+	Datasource  *Datasource  `json:"datasource,omitempty"`
+	Targets     []Target     `json:"targets,omitempty"`
+	FieldConfig *FieldConfig `json:"fieldConfig,omitempty"`
+}
+
+// GridPos is the position and size of a panel.
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Target is a query of a panel.
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+// FieldConfig holds the display defaults of a panel.
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+// FieldDefaults holds the unit of a panel.
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+var datasource = &Datasource{Type: "prometheus", UID: "${datasource}"}
+
+const (
+	panelHeight = 8
+	gridWidth   = 24
+)
+
+// New generates a dashboard with a row per strategy. Supported strategies are
+// RED, USE and FourGoldenSignals.
+func New(opts Opts, strategies ...strategy.Strategy) (*Dashboard, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	b := &builder{
+		opts: opts,
+		dashboard: &Dashboard{
+			UID:           opts.UID,
+			Title:         opts.Title,
+			Tags:          []string{"promstrap"},
+			Timezone:      "browser",
+			SchemaVersion: 36,
+			Time:          Time{From: "now-6h", To: "now"},
+			Templating: Templating{List: []Variable{{
+				Name:  "datasource",
+				Label: "Data source",
+				Type:  "datasource",
+				Query: "prometheus",
+			}}},
+		},
+		variables: map[string]bool{},
+	}
+
+	for _, s := range strategies {
+		switch v := s.(type) {
+		case *strategy.RED:
+			b.red(v)
+		case strategy.RED:
+			b.red(&v)
+		case *strategy.USE:
+			b.use(v)
+		case strategy.USE:
+			b.use(&v)
+		case *strategy.FourGoldenSignals:
+			b.fgs(v)
+		case strategy.FourGoldenSignals:
+			b.fgs(&v)
+		default:
+			return nil, fmt.Errorf("unsupported strategy %T, want RED, USE or FourGoldenSignals", s)
+		}
+	}
+
+	return b.dashboard, nil
+}
+
+// JSON renders the dashboard as Grafana dashboard JSON.
+func (d *Dashboard) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+type builder struct {
+	opts      Opts
+	dashboard *Dashboard
+	// variables are the labels a template variable was already created for.
+	variables map[string]bool
+	nextID    int
+	y         int
+}
+
+// metric is a metric as exposed, with its labels.
+type metric struct {
+	name   string
+	labels []string
+}
+
+func newMetric(namespace, name string, labels []string) metric {
+	return metric{name: prometheus.BuildFQName(namespace, "", name), labels: labels}
+}
+
+// selector renders the metric filtered by the template variables of its labels.
+func (m metric) selector(suffix string) string {
+	matchers := make([]string, len(m.labels))
+	for i, l := range m.labels {
+		matchers[i] = fmt.Sprintf("%s=~\"$%s\"", l, l)
+	}
+
+	return fmt.Sprintf("%s%s{%s}", m.name, suffix, strings.Join(matchers, ","))
+}
+
+func (b *builder) id() int {
+	b.nextID++
+
+	return b.nextID
+}
+
+// variablesFor adds a template variable for every label of m not seen yet.
+func (b *builder) variablesFor(m metric) {
+	for _, l := range m.labels {
+		if b.variables[l] {
+			continue
+		}
+		b.variables[l] = true
+
+		b.dashboard.Templating.List = append(b.dashboard.Templating.List, Variable{
+			Name:       l,
+			Type:       "query",
+			Query:      fmt.Sprintf("label_values(%s, %s)", m.name, l),
+			Datasource: datasource,
+			Refresh:    2,
+			IncludeAll: true,
+			Multi:      true,
+			AllValue:   ".*",
+		})
+	}
+}
+
+// row adds a row followed by panels laid out side by side.
+func (b *builder) row(title string, panels ...Panel) {
+	b.dashboard.Panels = append(b.dashboard.Panels, Panel{
+		ID:      b.id(),
+		Type:    "row",
+		Title:   title,
+		GridPos: GridPos{H: 1, W: gridWidth, X: 0, Y: b.y},
+	})
+	b.y++
+
+	width := gridWidth / len(panels)
+	for i, p := range panels {
+		p.ID = b.id()
+		p.GridPos = GridPos{H: panelHeight, W: width, X: i * width, Y: b.y}
+		b.dashboard.Panels = append(b.dashboard.Panels, p)
+	}
+	b.y += panelHeight
+}
+
+func timeseries(title, unit string, targets ...Target) Panel {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+
+	return Panel{
+		Type:        "timeseries",
+		Title:       title,
+		Datasource:  datasource,
+		Targets:     targets,
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unit}},
+	}
+}
+
+func legend(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("{{%s}}", l)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func sumBy(labels []string, expr string) string {
+	if len(labels) == 0 {
+		return fmt.Sprintf("sum(%s)", expr)
+	}
+
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(labels, ", "), expr)
+}
+
+func ratePanel(title, unit string, m metric) Panel {
+	return timeseries(title, unit, Target{
+		Expr:         sumBy(m.labels, fmt.Sprintf("rate(%s[$__rate_interval])", m.selector(""))),
+		LegendFormat: legend(m.labels),
+	})
+}
+
+// errorRatioPanel plots errors / requests, aggregated by the labels the two
+// counters share.
+func errorRatioPanel(errors, requests metric) Panel {
+	var labels []string
+	for _, l := range errors.labels {
+		for _, r := range requests.labels {
+			if l == r {
+				labels = append(labels, l)
+			}
+		}
+	}
+
+	target := Target{
+		Expr: fmt.Sprintf("%s\n/\n%s",
+			sumBy(labels, fmt.Sprintf("rate(%s[$__rate_interval])", errors.selector(""))),
+			sumBy(labels, fmt.Sprintf("rate(%s[$__rate_interval])", requests.selector("")))),
+		LegendFormat: legend(labels),
+	}
+	if len(labels) == 0 {
+		target.LegendFormat = "error ratio"
+	}
+
+	return timeseries("Error ratio", "percentunit", target)
+}
+
+func (b *builder) latencyPanel(title string, histogram metric) Panel {
+	quantiles := append([]float64(nil), b.opts.quantiles()...)
+	sort.Float64s(quantiles)
+
+	targets := make([]Target, len(quantiles))
+	for i, q := range quantiles {
+		percentile := strconv.FormatFloat(math.Round(q*1e6)/1e4, 'f', -1, 64)
+		targets[i] = Target{
+			Expr: fmt.Sprintf("histogram_quantile(%s, %s)", strconv.FormatFloat(q, 'f', -1, 64),
+				sumBy([]string{"le"}, fmt.Sprintf("rate(%s[$__rate_interval])", histogram.selector("_bucket")))),
+			LegendFormat: "p" + percentile,
+		}
+	}
+
+	return timeseries(title, "s", targets...)
+}
+
+func gaugePanel(title, unit string, m metric) Panel {
+	return timeseries(title, unit, Target{
+		Expr:         fmt.Sprintf("avg by (%s) (%s)", strings.Join(m.labels, ", "), m.selector("")),
+		LegendFormat: legend(m.labels),
+	})
+}
+
+func (b *builder) red(red *strategy.RED) {
+	o := red.Opts()
+	requests := newMetric(o.Namespace, red.RequestMetricName(), o.RequestsOpt.RequestLabels)
+	errors := newMetric(o.Namespace, red.ErrorMetricName(), o.ErrorsOpt.ErrorLabels)
+	duration := newMetric(o.Namespace, red.Duration.HistogramName(), o.DurationOpt.DurationLabels)
+
+	b.variablesFor(requests)
+	b.variablesFor(errors)
+	b.variablesFor(duration)
+
+	b.row("RED: "+requests.name,
+		ratePanel("Request rate", "reqps", requests),
+		errorRatioPanel(errors, requests),
+		b.latencyPanel("Request duration", duration),
+	)
+}
+
+func (b *builder) use(use *strategy.USE) {
+	o := use.Opts()
+	utilization := newMetric(o.Namespace, use.UtilizationMetricName(), o.UtilizationOpt.UtilizationLabels)
+	saturation := newMetric(o.Namespace, use.SaturationMetricName(), o.SaturationOpt.SaturationLabels)
+	errors := newMetric(o.Namespace, use.ErrorMetricName(), o.ErrorsOpt.ErrorLabels)
+
+	b.variablesFor(utilization)
+	b.variablesFor(saturation)
+	b.variablesFor(errors)
+
+	b.row("USE: "+utilization.name,
+		gaugePanel("Utilization", "none", utilization),
+		gaugePanel("Saturation", "none", saturation),
+		ratePanel("Error rate", "ops", errors),
+	)
+}
+
+func (b *builder) fgs(fgs *strategy.FourGoldenSignals) {
+	o := fgs.Opts()
+	latency := newMetric(o.Namespace, fgs.Latency.HistogramName(), o.LatencyOpt.LatencyLabels)
+	traffic := newMetric(o.Namespace, fgs.TrafficMetricName(), o.TrafficOpt.TrafficLabels)
+	errors := newMetric(o.Namespace, fgs.ErrorMetricName(), o.ErrorsOpt.ErrorLabels)
+	saturation := newMetric(o.Namespace, fgs.SaturationMetricName(), o.SaturationOpt.SaturationLabels)
+
+	b.variablesFor(latency)
+	b.variablesFor(traffic)
+	b.variablesFor(errors)
+	b.variablesFor(saturation)
+
+	b.row("Four Golden Signals: "+traffic.name,
+		b.latencyPanel("Latency", latency),
+		ratePanel("Traffic", "reqps", traffic),
+		errorRatioPanel(errors, traffic),
+		gaugePanel("Saturation", "none", saturation),
+	)
+}
@@ -0,0 +1,202 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func newTestStrategies(t *testing.T) (*strategy.RED, *strategy.USE, *strategy.FourGoldenSignals) {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "service",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"path", "verb"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: []string{"path", "error"},
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"path"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	use, err := strategy.NewUSE(strategy.USEOpts{
+		Namespace: "system",
+		UtilizationOpt: strategy.USEUtilizationOpt{
+			UtilizationName:   "memory_utilization_ratio",
+			UtilizationHelp:   "Memory utilization as a ratio of used to total",
+			UtilizationLabels: []string{"type"},
+		},
+		SaturationOpt: strategy.USESaturationOpt{
+			SaturationName:   "memory_saturation_bytes",
+			SaturationHelp:   "Amount of memory queued/waiting to be freed",
+			SaturationLabels: []string{"type"},
+		},
+		ErrorsOpt: strategy.USEErrorsOpt{
+			ErrorLabels: []string{"type"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: "service",
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   "http_request_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "HTTP request latency in seconds",
+			LatencyLabels: []string{"method", "path"},
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   "http_server_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Total number of HTTP requests",
+			TrafficLabels: []string{"method", "path", "status"},
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"type"},
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   "memory_heap_saturation_bytes",
+			SaturationHelp:   "Memory heap usage in bytes",
+			SaturationLabels: []string{"gc_type"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red, use, fgs
+}
+
+func TestNewGolden(t *testing.T) {
+	t.Parallel()
+
+	red, use, fgs := newTestStrategies(t)
+
+	d, err := New(Opts{Title: "Service", UID: "service"}, red, use, fgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile("testdata/dashboard.json", got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile("testdata/dashboard.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.JSONEq(t, string(want), string(got))
+}
+
+func TestNewUsesMetricNames(t *testing.T) {
+	t.Parallel()
+
+	red, use, fgs := newTestStrategies(t)
+
+	d, err := New(Opts{Title: "Service"}, red, use, fgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exprs []string
+	for _, p := range d.Panels {
+		for _, target := range p.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	all := strings.Join(exprs, "\n")
+
+	for _, name := range []string{
+		red.RequestMetricName(),
+		red.ErrorMetricName(),
+		red.Duration.HistogramName() + "_bucket",
+		use.UtilizationMetricName(),
+		use.SaturationMetricName(),
+		use.ErrorMetricName(),
+		fgs.Latency.HistogramName() + "_bucket",
+		fgs.TrafficMetricName(),
+		fgs.ErrorMetricName(),
+		fgs.SaturationMetricName(),
+	} {
+		assert.Contains(t, all, name)
+	}
+}
+
+func TestNewVariables(t *testing.T) {
+	t.Parallel()
+
+	red, _, _ := newTestStrategies(t)
+
+	d, err := New(Opts{Title: "Service"}, *red)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, v := range d.Templating.List {
+		names = append(names, v.Name)
+	}
+
+	// Labels shared by several metrics only get a single variable.
+	assert.Equal(t, []string{"datasource", "path", "verb", "error"}, names)
+	assert.Equal(t, "label_values(service_http_requests_total, path)", d.Templating.List[1].Query)
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(Opts{})
+	assert.Error(t, err)
+
+	distribution, err := strategy.NewDistribution(strategy.DistributionOpts{
+		Namespace: "foo",
+		Name:      "bar",
+		Help:      "baz",
+		Labels:    []string{"qux"},
+	})
+	assert.NoError(t, err)
+
+	_, err = New(Opts{Title: "Service"}, distribution)
+	assert.EqualError(t, err, "unsupported strategy *strategy.Distribution, want RED, USE or FourGoldenSignals")
+}
+
+func TestJSONIsValid(t *testing.T) {
+	t.Parallel()
+
+	_, use, _ := newTestStrategies(t)
+
+	d, err := New(Opts{Title: "Resources"}, use)
+	assert.NoError(t, err)
+
+	raw, err := d.JSON()
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, "Resources", decoded["title"])
+}
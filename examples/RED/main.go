@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rabellamy/promstrap/httpmetrics"
 	"github.com/rabellamy/promstrap/strategy"
 )
 
@@ -53,31 +54,22 @@ func main() {
 		http.ListenAndServe(":2112", nil)
 	}()
 
+	// Records requests, errors (5xx) and durations of every route, labelled
+	// by chi route pattern
+	mw, err := httpmetrics.ChiRED(redExample, httpmetrics.Opts{})
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
 	r := chi.NewRouter()
+	r.Use(mw)
 	r.Get("/happy", func(w http.ResponseWriter, r *http.Request) {
-		t := time.Now()
-		// Records that a request took place
-		redExample.Requests.WithLabelValues("/happy", "GET").Inc()
-
 		doTheWork(workTimeBox{
 			min: .01,
 			max: 3.0,
 		})
 
-		_, err = w.Write([]byte("You are now happy!!\n"))
-		if err != nil {
-			// Records the error
-			redExample.Errors.WithLabelValues(err.Error()).Inc()
-		}
-
-		// Calculates how long the request takes
-		ts := time.Since(t).Seconds()
-
-		// Records the duration of the request with a histogram
-		redExample.Duration.Histogram.WithLabelValues("/happy").Observe(ts)
-
-		// Records the duration of the request with a summary
-		redExample.Duration.Summary.WithLabelValues("/happy").Observe(ts)
+		w.Write([]byte("You are now happy!!\n"))
 	})
 
 	err = http.ListenAndServe(":8080", r)
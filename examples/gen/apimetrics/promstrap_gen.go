@@ -0,0 +1,66 @@
+// Code generated by promstrap-gen. DO NOT EDIT.
+
+package apimetrics
+
+import "github.com/rabellamy/promstrap/strategy"
+
+// API records a RED strategy with named label arguments.
+type API struct {
+	// Strategy is the Prometheus backed strategy. It is nil when API was
+	// created with NewAPIWithRecorder.
+	Strategy *strategy.RED
+
+	recorder strategy.REDRecorder
+}
+
+// NewAPI creates API backed by a RED strategy.
+func NewAPI() (*API, error) {
+	s, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "bar",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"path", "verb"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: []string{"error"},
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"path"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &API{Strategy: s, recorder: s}, nil
+}
+
+// NewAPIWithRecorder creates API backed by recorder, such as a
+// no-op or a strategytest fake.
+func NewAPIWithRecorder(recorder strategy.REDRecorder) *API {
+	return &API{recorder: recorder}
+}
+
+// Register registers the strategy with the Prometheus DefaultRegisterer.
+func (m *API) Register() error {
+	if m.Strategy == nil {
+		return nil
+	}
+
+	return m.Strategy.Register()
+}
+
+// RecordRequest records that a request took place.
+func (m *API) RecordRequest(path, verb string) {
+	m.recorder.ObserveRequest(path, verb)
+}
+
+// RecordError records that a request failed.
+func (m *API) RecordError(labelError string) {
+	m.recorder.ObserveError(labelError)
+}
+
+// RecordDuration records how long a request took in seconds.
+func (m *API) RecordDuration(seconds float64, path string) {
+	m.recorder.ObserveDuration(seconds, path)
+}
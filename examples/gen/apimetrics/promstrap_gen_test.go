@@ -0,0 +1,53 @@
+// Code generated by promstrap-gen. DO NOT EDIT.
+
+package apimetrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rabellamy/promstrap/strategy/strategytest"
+)
+
+func TestAPIMetricNames(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := m.Strategy.RequestMetricName(), "http_requests_total"; got != want {
+		t.Errorf("RequestMetricName() = %q, want %q", got, want)
+	}
+
+	if got, want := m.Strategy.ErrorMetricName(), "errors_total"; got != want {
+		t.Errorf("ErrorMetricName() = %q, want %q", got, want)
+	}
+
+	if got, want := m.Strategy.DurationMetricName(), "http_request_duration_seconds"; got != want {
+		t.Errorf("DurationMetricName() = %q, want %q", got, want)
+	}
+}
+
+func TestAPILabelOrder(t *testing.T) {
+	t.Parallel()
+
+	fake := &strategytest.RED{}
+	m := NewAPIWithRecorder(fake)
+
+	m.RecordRequest("path", "verb")
+	if got, want := fake.Requests()[0].Labels, []string{"path", "verb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordRequest() labels = %v, want %v", got, want)
+	}
+
+	m.RecordError("error")
+	if got, want := fake.Errors()[0].Labels, []string{"error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordError() labels = %v, want %v", got, want)
+	}
+
+	m.RecordDuration(1, "path")
+	if got, want := fake.Durations()[0].Labels, []string{"path"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordDuration() labels = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+//go:generate go run ../../cmd/promstrap-gen -in metrics.yaml -out ./apimetrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rabellamy/promstrap/examples/gen/apimetrics"
+)
+
+func main() {
+	api, err := apimetrics.NewAPI()
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
+	// register metrics
+	err = api.Register()
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
+	go func() {
+		http.Handle("/metrics", promhttp.Handler())
+		http.ListenAndServe(":2112", nil)
+	}()
+
+	r := chi.NewRouter()
+	r.Get("/happy", func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		// Records that a request took place, with one named argument per
+		// label
+		api.RecordRequest("/happy", "GET")
+
+		_, err = w.Write([]byte("You are now happy!!\n"))
+		if err != nil {
+			// Records the error
+			api.RecordError(err.Error())
+		}
+
+		// Records the duration of the request with a histogram and a summary
+		api.RecordDuration(time.Since(t).Seconds(), "/happy")
+	})
+
+	err = http.ListenAndServe(":8080", r)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
@@ -0,0 +1,42 @@
+package httpmetrics
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Unmatched is the route label of requests no chi route matched.
+const Unmatched = "unmatched"
+
+// ChiRoute returns the route pattern chi matched for r, e.g. "/users/{id}",
+// or Unmatched. It must be called after the request was routed, which is the
+// case for Opts.Route when the middleware is installed with chi's Use.
+func ChiRoute(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return Unmatched
+	}
+
+	pattern := rctx.RoutePattern()
+	if pattern == "" {
+		return Unmatched
+	}
+
+	return pattern
+}
+
+// ChiRED is RED labelling requests by their chi route pattern.
+func ChiRED(red *strategy.RED, opts Opts) (func(http.Handler) http.Handler, error) {
+	opts.Route = ChiRoute
+
+	return RED(red, opts)
+}
+
+// ChiFGS is FGS labelling requests by their chi route pattern.
+func ChiFGS(fgs *strategy.FourGoldenSignals, opts FGSOpts) (func(http.Handler) http.Handler, error) {
+	opts.Route = ChiRoute
+
+	return FGS(fgs, opts)
+}
@@ -0,0 +1,44 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChiRED(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "route", "status")
+	mw, err := ChiRED(red, Opts{})
+	assert.NoError(t, err)
+
+	api := chi.NewRouter()
+	api.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	r := chi.NewRouter()
+	r.Use(mw)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Mount("/api", api)
+
+	serve(r, http.MethodGet, "/users/1")
+	serve(r, http.MethodGet, "/users/2")
+	serve(r, http.MethodGet, "/api/orders/3")
+	serve(r, http.MethodGet, "/nope")
+	serve(r, http.MethodGet, "/nope/either")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/users/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/api/orders/{id}", "200")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", Unmatched, "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(red.Requests))
+}
+
+func TestChiRouteOutsideChi(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Unmatched, ChiRoute(httptest.NewRequest(http.MethodGet, "/users/1", nil)))
+}
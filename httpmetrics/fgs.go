@@ -0,0 +1,101 @@
+package httpmetrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-playground/validator"
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// FGSOpts is the options to create a FourGoldenSignals middleware.
+type FGSOpts struct {
+	Opts
+	// Capacity is the number of requests the server can serve concurrently.
+	// Saturation is the ratio of in-flight requests to Capacity.
+	Capacity int `validate:"gt=0"`
+	// SaturationLabelValues are the values of the saturation labels of the
+	// strategy, e.g. the name of the server.
+	SaturationLabelValues []string
+}
+
+// FGS returns a middleware recording the four golden signals of every
+// request with a FourGoldenSignals strategy: it observes the latency into
+// both the histogram and the summary, counts the request as traffic, counts
+// an error when Opts.IsError fires for the response status, and sets the
+// saturation to the ratio of in-flight requests to FGSOpts.Capacity. Use the
+// outcome label to split latency between successes and errors. A handler
+// that panics is recorded as a 500. It fails if a label of the strategy is
+// not supported.
+func FGS(fgs *strategy.FourGoldenSignals, opts FGSOpts) (func(http.Handler) http.Handler, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	o := fgs.Opts()
+
+	if len(opts.SaturationLabelValues) != len(o.SaturationOpt.SaturationLabels) {
+		return nil, fmt.Errorf("got %d saturation label values for labels %v",
+			len(opts.SaturationLabelValues), o.SaturationOpt.SaturationLabels)
+	}
+
+	latencyLabels, err := labels.New(labelValues, o.LatencyOpt.LatencyLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	trafficLabels, err := labels.New(labelValues, o.TrafficOpt.TrafficLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := &inFlight{
+		fgs:         fgs,
+		capacity:    float64(opts.Capacity),
+		labelValues: opts.SaturationLabelValues,
+	}
+	tracker.add(0)
+
+	return func(next http.Handler) http.Handler {
+		h := instrument(next, opts.Opts, func(req request, seconds float64) {
+			fgs.ObserveLatency(seconds, latencyLabels.Values(req)...)
+			fgs.ObserveTraffic(trafficLabels.Values(req)...)
+			if req.failed {
+				fgs.ObserveError(errorLabels.Values(req)...)
+			}
+		})
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tracker.add(1)
+			defer tracker.add(-1)
+
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// inFlight tracks the requests being served and sets the saturation.
+type inFlight struct {
+	fgs         *strategy.FourGoldenSignals
+	capacity    float64
+	labelValues []string
+
+	mu sync.Mutex
+	n  int
+}
+
+func (i *inFlight) add(delta int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.n += delta
+	i.fgs.SetSaturation(float64(i.n)/i.capacity, i.labelValues...)
+}
@@ -0,0 +1,144 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFGS(t *testing.T, latencyLabels ...string) *strategy.FourGoldenSignals {
+	t.Helper()
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: "service",
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   "http_request_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "HTTP request latency in seconds",
+			LatencyLabels: latencyLabels,
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   "http_server_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Total number of HTTP requests",
+			TrafficLabels: []string{"method", "route"},
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"route", "status"},
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   "http_server_saturation_ratio",
+			SaturationHelp:   "Ratio of in-flight requests to capacity",
+			SaturationLabels: []string{"server"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fgs
+}
+
+func TestFGS(t *testing.T) {
+	t.Parallel()
+
+	fgs := newTestFGS(t, "route", "outcome")
+	mw, err := ChiFGS(fgs, FGSOpts{
+		Capacity:              4,
+		SaturationLabelValues: []string{"api"},
+	})
+	assert.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(mw)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	serve(r, http.MethodGet, "/users/1")
+	serve(r, http.MethodGet, "/users/2")
+	serve(r, http.MethodPost, "/users/3")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("GET", "/users/{id}")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("POST", "/users/{id}")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Errors.WithLabelValues("/users/{id}", "502")))
+	assert.Equal(t, 1, testutil.CollectAndCount(fgs.Errors))
+	assert.Equal(t, 2, testutil.CollectAndCount(fgs.Latency.Histogram))
+	assert.Equal(t, 0.0, testutil.ToFloat64(fgs.Saturation.WithLabelValues("api")))
+}
+
+func TestFGSSaturation(t *testing.T) {
+	t.Parallel()
+
+	fgs := newTestFGS(t, "outcome")
+	mw, err := FGS(fgs, FGSOpts{
+		Capacity:              4,
+		SaturationLabelValues: []string{"api"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(fgs.Saturation.WithLabelValues("api")))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(h, http.MethodGet, "/")
+		}()
+		<-started
+	}
+
+	assert.Equal(t, 0.75, testutil.ToFloat64(fgs.Saturation.WithLabelValues("api")))
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(fgs.Saturation.WithLabelValues("api")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("GET", "/")))
+}
+
+func TestFGSErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fgs  *strategy.FourGoldenSignals
+		opts FGSOpts
+	}{
+		"missing capacity": {
+			fgs:  newTestFGS(t, "route"),
+			opts: FGSOpts{SaturationLabelValues: []string{"api"}},
+		},
+		"saturation label values mismatch": {
+			fgs:  newTestFGS(t, "route"),
+			opts: FGSOpts{Capacity: 1},
+		},
+		"unsupported label": {
+			fgs:  newTestFGS(t, "tenant"),
+			opts: FGSOpts{Capacity: 1, SaturationLabelValues: []string{"api"}},
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			_, err := FGS(tt.fgs, tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package httpmetrics instruments net/http handlers with strategies.
+//
+// The label values of every metric are derived from the label names the
+// strategy was created with:
+//
+//	method, verb               the request method, e.g. "GET"
+//	route, path, handler       the route of the request, see Opts.Route
+//	status, code, status_code  the response status code, e.g. "200"
+//	status_class               the response status class, e.g. "2xx"
+//	outcome                    "error" when Opts.IsError fires, "success" otherwise
+//	error                      the response status text, e.g. "Internal Server Error"
+package httpmetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Opts is the options to create a middleware.
+type Opts struct {
+	// IsError reports whether a response status counts as an error. If not
+	// specified, defaults to 5xx statuses.
+	IsError func(status int) bool
+	// Route returns the route label of a request once it was served. If not
+	// specified, defaults to the URL path, which is only safe when the set of
+	// paths is bounded.
+	Route func(r *http.Request) string
+}
+
+func (o Opts) isError(status int) bool {
+	if o.IsError != nil {
+		return o.IsError(status)
+	}
+
+	return status >= 500
+}
+
+func (o Opts) route(r *http.Request) string {
+	if o.Route != nil {
+		return o.Route(r)
+	}
+
+	return r.URL.Path
+}
+
+// request holds what label values are derived from.
+type request struct {
+	method string
+	route  string
+	status int
+	failed bool
+}
+
+var labelValues = map[string]func(request) string{
+	"method":       func(r request) string { return r.method },
+	"verb":         func(r request) string { return r.method },
+	"route":        func(r request) string { return r.route },
+	"path":         func(r request) string { return r.route },
+	"handler":      func(r request) string { return r.route },
+	"status":       func(r request) string { return strconv.Itoa(r.status) },
+	"code":         func(r request) string { return strconv.Itoa(r.status) },
+	"status_code":  func(r request) string { return strconv.Itoa(r.status) },
+	"status_class": func(r request) string { return statusClass(r.status) },
+	"outcome":      func(r request) string { return outcome(r.failed) },
+	"error":        func(r request) string { return http.StatusText(r.status) },
+}
+
+// RED returns a middleware recording every request with a RED strategy: it
+// counts the request, counts an error when Opts.IsError fires for the
+// response status, and observes the duration into both the histogram and the
+// summary. A handler that panics is recorded as a 500. It fails if a label
+// of the strategy is not supported.
+func RED(red *strategy.RED, opts Opts) (func(http.Handler) http.Handler, error) {
+	o := red.Opts()
+
+	requestLabels, err := labels.New(labelValues, o.RequestsOpt.RequestLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	durationLabels, err := labels.New(labelValues, o.DurationOpt.DurationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return instrument(next, opts, func(req request, seconds float64) {
+			red.ObserveRequest(requestLabels.Values(req)...)
+			if req.failed {
+				red.ObserveError(errorLabels.Values(req)...)
+			}
+			red.ObserveDuration(seconds, durationLabels.Values(req)...)
+		})
+	}, nil
+}
+
+// instrument returns a handler serving requests with next and calling record
+// once each one was served. A request whose handler panics is recorded as a
+// 500 before the panic moves on to net/http.
+func instrument(next http.Handler, opts Opts, record func(req request, seconds float64)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww, rw := wrap(w)
+
+		defer func() {
+			req := request{
+				method: r.Method,
+				route:  opts.route(r),
+				status: rw.Status(),
+			}
+
+			p := recover()
+			if p != nil {
+				req.status = http.StatusInternalServerError
+			}
+			req.failed = opts.isError(req.status)
+
+			record(req, time.Since(start).Seconds())
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
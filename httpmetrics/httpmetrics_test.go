@@ -0,0 +1,141 @@
+package httpmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRED(t *testing.T, errorLabels ...string) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "service",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"method", "route", "status"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: errorLabels,
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"route"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+
+	return rec
+}
+
+func TestRED(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "route", "code", "error")
+	mw, err := RED(red, Opts{})
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h := mw(mux)
+
+	assert.Equal(t, "ok", serve(h, http.MethodGet, "/ok").Body.String())
+	serve(h, http.MethodGet, "/ok")
+	serve(h, http.MethodPost, "/missing")
+	serve(h, http.MethodGet, "/boom")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/ok", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("POST", "/missing", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/boom", "503")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Errors))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("/boom", "503", "Service Unavailable")))
+	assert.Equal(t, 3, testutil.CollectAndCount(red.Duration.Histogram))
+	assert.Equal(t, 3, testutil.CollectAndCount(red.Duration.Summary))
+}
+
+func TestREDOpts(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "route")
+	mw, err := RED(red, Opts{
+		IsError: func(status int) bool { return status >= 400 },
+		Route:   func(r *http.Request) string { return "/users/{id}" },
+	})
+	assert.NoError(t, err)
+
+	h := mw(http.NotFoundHandler())
+	serve(h, http.MethodGet, "/users/1")
+	serve(h, http.MethodGet, "/users/2")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/users/{id}", "404")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Errors.WithLabelValues("/users/{id}")))
+}
+
+func TestREDPanic(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "status")
+	mw, err := RED(red, Opts{})
+	assert.NoError(t, err)
+
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	assert.PanicsWithValue(t, "boom", func() { serve(h, http.MethodGet, "/") })
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("500")))
+}
+
+func TestREDUnsupportedLabel(t *testing.T) {
+	t.Parallel()
+
+	_, err := RED(newTestRED(t, "tenant"), Opts{})
+	assert.EqualError(t, err, `label "tenant" is not one of code, error, handler, method, outcome, path, route, status, status_class, status_code, verb`)
+}
+
+func TestLabelValues(t *testing.T) {
+	t.Parallel()
+
+	req := request{method: "PUT", route: "/users/{id}", status: 503, failed: true}
+
+	tests := map[string]string{
+		"method":       "PUT",
+		"verb":         "PUT",
+		"route":        "/users/{id}",
+		"path":         "/users/{id}",
+		"handler":      "/users/{id}",
+		"status":       "503",
+		"code":         "503",
+		"status_code":  "503",
+		"status_class": "5xx",
+		"outcome":      "error",
+		"error":        "Service Unavailable",
+	}
+
+	for label, want := range tests {
+		assert.Equal(t, want, labelValues[label](req), label)
+	}
+
+	assert.Equal(t, "success", labelValues["outcome"](request{status: 200}))
+}
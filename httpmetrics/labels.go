@@ -0,0 +1,23 @@
+package httpmetrics
+
+import (
+	"strconv"
+)
+
+// statusClass returns the class of a status code, e.g. "5xx", or "none" when
+// there is no status.
+func statusClass(status int) string {
+	if status == 0 {
+		return "none"
+	}
+
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func outcome(failed bool) string {
+	if failed {
+		return "error"
+	}
+
+	return "success"
+}
@@ -0,0 +1,184 @@
+package httpmetrics
+
+import (
+	"container/list"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator"
+)
+
+// Other is the route label of paths past the cardinality limit of a
+// Normalizer.
+const Other = "other"
+
+var (
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	numericSegment = regexp.MustCompile(`^[0-9]+$`)
+	hexSegment     = regexp.MustCompile(`^[0-9a-fA-F]{8,}$`)
+	digit          = regexp.MustCompile(`[0-9]`)
+	emailSegment   = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// Normalizer turns request paths into route templates for routers without
+// route patterns, such as http.ServeMux. Paths matching a declared template
+// are labelled with it; the segments of other paths that look like
+// identifiers are replaced with placeholders:
+//
+//	{uuid}   UUIDs
+//	{id}     numeric IDs
+//	{hash}   hex hashes
+//	{email}  email-like segments
+//
+// Past MaxRoutes distinct templates, paths are labelled Other. Use Route as
+// Opts.Route.
+type Normalizer struct {
+	templates [][]string
+	opts      NormalizerOpts
+
+	mu     sync.Mutex
+	routes map[string]struct{}
+	cache  *list.List
+	cached map[string]*list.Element
+}
+
+// NormalizerOpts is the options to create a Normalizer.
+type NormalizerOpts struct {
+	// Templates are route templates whose {name} segments match any segment,
+	// e.g. "/orders/{id}/items". The first matching template wins.
+	Templates []string `validate:"dive,startswith=/"`
+	// MaxRoutes is the number of distinct routes, declared templates aside,
+	// before paths are labelled Other. If not specified, defaults to 100.
+	MaxRoutes int `validate:"gte=0"`
+	// CacheSize is the number of paths whose route is kept in a least
+	// recently used cache. If not specified, defaults to 1000.
+	CacheSize int `validate:"gte=0"`
+}
+
+// cacheEntry is a path and its route.
+type cacheEntry struct {
+	path  string
+	route string
+}
+
+// NewNormalizer creates a Normalizer.
+func NewNormalizer(opts NormalizerOpts) (*Normalizer, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.MaxRoutes == 0 {
+		opts.MaxRoutes = 100
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = 1000
+	}
+
+	templates := make([][]string, 0, len(opts.Templates))
+	for _, t := range opts.Templates {
+		templates = append(templates, strings.Split(t, "/"))
+	}
+
+	return &Normalizer{
+		templates: templates,
+		opts:      opts,
+		routes:    map[string]struct{}{},
+		cache:     list.New(),
+		cached:    map[string]*list.Element{},
+	}, nil
+}
+
+// Route returns the route label of the URL path of r.
+func (n *Normalizer) Route(r *http.Request) string {
+	return n.Normalize(r.URL.Path)
+}
+
+// Normalize returns the route template of path.
+func (n *Normalizer) Normalize(path string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if e, ok := n.cached[path]; ok {
+		n.cache.MoveToFront(e)
+
+		return e.Value.(cacheEntry).route
+	}
+
+	route := n.normalize(path)
+
+	n.cached[path] = n.cache.PushFront(cacheEntry{path: path, route: route})
+	if n.cache.Len() > n.opts.CacheSize {
+		oldest := n.cache.Back()
+		n.cache.Remove(oldest)
+		delete(n.cached, oldest.Value.(cacheEntry).path)
+	}
+
+	return route
+}
+
+// normalize must be called with mu held.
+func (n *Normalizer) normalize(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, t := range n.templates {
+		if matchTemplate(t, segments) {
+			return n.opts.Templates[i]
+		}
+	}
+
+	for i, s := range segments {
+		segments[i] = placeholder(s)
+	}
+	route := strings.Join(segments, "/")
+
+	if _, ok := n.routes[route]; ok {
+		return route
+	}
+	if len(n.routes) >= n.opts.MaxRoutes {
+		return Other
+	}
+	n.routes[route] = struct{}{}
+
+	return route
+}
+
+func matchTemplate(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return false
+			}
+
+			continue
+		}
+		if t != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// placeholder returns the placeholder of a segment that looks like an
+// identifier, or the segment itself.
+func placeholder(segment string) string {
+	switch {
+	case uuidSegment.MatchString(segment):
+		return "{uuid}"
+	case numericSegment.MatchString(segment):
+		return "{id}"
+	case hexSegment.MatchString(segment) && digit.MatchString(segment):
+		return "{hash}"
+	case emailSegment.MatchString(segment):
+		return "{email}"
+	default:
+		return segment
+	}
+}
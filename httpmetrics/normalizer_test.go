@@ -0,0 +1,115 @@
+package httpmetrics
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	n, err := NewNormalizer(NormalizerOpts{
+		Templates: []string{"/orders/{id}/items", "/files/{name}"},
+	})
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"/":           "/",
+		"/healthz":    "/healthz",
+		"/users/123":  "/users/{id}",
+		"/users/123/": "/users/{id}/",
+		"/users/f47ac10b-58cc-4372-a567-0e02b2c3d479":            "/users/{uuid}",
+		"/blobs/9e107d9d372bb6826bd81d3542a419d6":                "/blobs/{hash}",
+		"/commits/a1b2c3d4e5":                                    "/commits/{hash}",
+		"/facade/deadbeef":                                       "/facade/deadbeef",
+		"/users/jane.doe@example.com/settings":                   "/users/{email}/settings",
+		"/orders/ABC-1/items":                                    "/orders/{id}/items",
+		"/orders//items":                                         "/orders//items",
+		"/files/report.pdf":                                      "/files/{name}",
+		"/teams/42/members/f47ac10b-58cc-4372-a567-0e02b2c3d479": "/teams/{id}/members/{uuid}",
+	}
+
+	for path, want := range tests {
+		assert.Equal(t, want, n.Normalize(path), path)
+	}
+}
+
+func TestNormalizeCardinalityLimit(t *testing.T) {
+	t.Parallel()
+
+	n, err := NewNormalizer(NormalizerOpts{
+		Templates: []string{"/orders/{id}"},
+		MaxRoutes: 2,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "/a", n.Normalize("/a"))
+	assert.Equal(t, "/b/{id}", n.Normalize("/b/1"))
+	assert.Equal(t, Other, n.Normalize("/c"))
+	// Known routes and declared templates are not affected by the limit.
+	assert.Equal(t, "/b/{id}", n.Normalize("/b/2"))
+	assert.Equal(t, "/orders/{id}", n.Normalize("/orders/abc"))
+}
+
+func TestNormalizeCache(t *testing.T) {
+	t.Parallel()
+
+	n, err := NewNormalizer(NormalizerOpts{CacheSize: 2})
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "/users/{id}", n.Normalize(fmt.Sprintf("/users/%d", i)))
+	}
+	assert.Equal(t, 2, n.cache.Len())
+	assert.Len(t, n.cached, 2)
+
+	// The most recently used paths are kept.
+	n.Normalize("/users/8")
+	n.Normalize("/users/10")
+	assert.Contains(t, n.cached, "/users/8")
+	assert.Contains(t, n.cached, "/users/10")
+	assert.NotContains(t, n.cached, "/users/9")
+}
+
+func TestNewNormalizerErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]NormalizerOpts{
+		"relative template": {Templates: []string{"orders/{id}"}},
+		"negative limit":    {MaxRoutes: -1},
+		"negative cache":    {CacheSize: -1},
+	}
+
+	for name, opts := range tests {
+		opts := opts
+
+		t.Run(name, func(t *testing.T) {
+			_, err := NewNormalizer(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNormalizerRoute(t *testing.T) {
+	t.Parallel()
+
+	n, err := NewNormalizer(NormalizerOpts{})
+	assert.NoError(t, err)
+
+	red := newTestRED(t, "route")
+	mw, err := RED(red, Opts{Route: n.Route})
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {})
+	h := mw(mux)
+
+	serve(h, http.MethodGet, "/users/1")
+	serve(h, http.MethodGet, "/users/2")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("GET", "/users/{id}", "200")))
+}
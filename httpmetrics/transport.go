@@ -0,0 +1,369 @@
+package httpmetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/metrics"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Transport is an http.RoundTripper recording outbound requests with a RED
+// or FourGoldenSignals strategy. The duration of a request is the time until
+// its response headers were received.
+//
+// The label values of every metric are derived from the label names the
+// strategy was created with:
+//
+//	method, verb               the request method, e.g. "GET"
+//	host                       the target host, e.g. "api.example.com:443"
+//	operation                  the operation name, see WithOperation
+//	status, code, status_code  the response status code, e.g. "200", or "0"
+//	status_class               the response status class, e.g. "2xx", or "none"
+//	outcome                    "error" for errors, "success" otherwise
+//	error_class                see below
+//
+// Errors are requests that failed with a transport error, or whose response
+// status fired TransportOpts.IsError. Their error_class is bounded to "dns",
+// "connect", "tls", "timeout", "canceled" and "other" for transport errors,
+// and to the status class, e.g. "5xx", otherwise.
+type Transport struct {
+	// Phases is the duration of the phases of requests in seconds, by host
+	// and phase: "dns", "connect", "tls" and "first_byte", the time to first
+	// response byte. It is nil unless TransportOpts.Trace is set. Every
+	// traced Transport of a namespace shares it once registered.
+	Phases *prometheus.HistogramVec
+
+	next   http.RoundTripper
+	opts   TransportOpts
+	record func(c call, seconds float64)
+
+	// mu guards Phases, which Register swaps for the one of the namespace.
+	mu sync.Mutex
+}
+
+// TransportOpts is the options to create a Transport.
+type TransportOpts struct {
+	// Next is the RoundTripper requests are sent with. If not specified,
+	// defaults to http.DefaultTransport.
+	Next http.RoundTripper
+	// IsError reports whether a response status counts as an error. If not
+	// specified, defaults to 5xx statuses.
+	IsError func(status int) bool
+	// Trace enables the Phases histogram, measured with httptrace.
+	Trace bool
+	// TraceBuckets defines the buckets of the Phases histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	TraceBuckets []float64
+}
+
+func (o TransportOpts) isError(status int) bool {
+	if o.IsError != nil {
+		return o.IsError(status)
+	}
+
+	return status >= 500
+}
+
+// call holds what label values of an outbound request are derived from.
+type call struct {
+	method     string
+	host       string
+	operation  string
+	status     int
+	failed     bool
+	errorClass string
+}
+
+var transportLabelValues = map[string]func(call) string{
+	"method":       func(c call) string { return c.method },
+	"verb":         func(c call) string { return c.method },
+	"host":         func(c call) string { return c.host },
+	"operation":    func(c call) string { return c.operation },
+	"status":       func(c call) string { return strconv.Itoa(c.status) },
+	"code":         func(c call) string { return strconv.Itoa(c.status) },
+	"status_code":  func(c call) string { return strconv.Itoa(c.status) },
+	"status_class": func(c call) string { return statusClass(c.status) },
+	"outcome":      func(c call) string { return outcome(c.failed) },
+	"error_class":  func(c call) string { return c.errorClass },
+}
+
+type operationKey struct{}
+
+// WithOperation returns a copy of ctx naming the operation of the outbound
+// requests made with it, e.g. "get-user". Requests without an operation are
+// labelled "unknown".
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+func operationFrom(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+
+	return "unknown"
+}
+
+// NewREDTransport creates a Transport recording every request with a RED
+// strategy. It fails if a label of the strategy is not supported.
+func NewREDTransport(red *strategy.RED, opts TransportOpts) (*Transport, error) {
+	o := red.Opts()
+
+	requestLabels, err := labels.New(transportLabelValues, o.RequestsOpt.RequestLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(transportLabelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	durationLabels, err := labels.New(transportLabelValues, o.DurationOpt.DurationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransport(o.Namespace, opts, func(c call, seconds float64) {
+		red.ObserveRequest(requestLabels.Values(c)...)
+		if c.failed {
+			red.ObserveError(errorLabels.Values(c)...)
+		}
+		red.ObserveDuration(seconds, durationLabels.Values(c)...)
+	})
+}
+
+// NewFGSTransport creates a Transport recording the latency, traffic and
+// errors of every request with a FourGoldenSignals strategy. It fails if a
+// label of the strategy is not supported.
+func NewFGSTransport(fgs *strategy.FourGoldenSignals, opts TransportOpts) (*Transport, error) {
+	o := fgs.Opts()
+
+	latencyLabels, err := labels.New(transportLabelValues, o.LatencyOpt.LatencyLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	trafficLabels, err := labels.New(transportLabelValues, o.TrafficOpt.TrafficLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(transportLabelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTransport(o.Namespace, opts, func(c call, seconds float64) {
+		fgs.ObserveLatency(seconds, latencyLabels.Values(c)...)
+		fgs.ObserveTraffic(trafficLabels.Values(c)...)
+		if c.failed {
+			fgs.ObserveError(errorLabels.Values(c)...)
+		}
+	})
+}
+
+func newTransport(namespace string, opts TransportOpts, record func(c call, seconds float64)) (*Transport, error) {
+	next := opts.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &Transport{
+		next:   next,
+		opts:   opts,
+		record: record,
+	}
+
+	if opts.Trace {
+		phases, err := metrics.NewHistogramWithLabels(metrics.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_client_phase_duration_seconds",
+			Help:      "Duration of the phases of outbound HTTP requests in seconds",
+			Labels:    []string{"host", "phase"},
+			Buckets:   opts.TraceBuckets,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.Phases = phases
+	}
+
+	return t, nil
+}
+
+// Register registers the Phases histogram, if any, with the Prometheus
+// DefaultRegisterer. The histogram has the same name for every traced
+// Transport of a namespace, so one already registered by another Transport
+// is shared, labelled by host, buckets included. The strategy is registered
+// on its own.
+func (t *Transport) Register() error {
+	phases := t.phases()
+	if phases == nil {
+		return nil
+	}
+
+	err := metrics.RegisterCollectors(phases)
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		existing, ok := already.ExistingCollector.(*prometheus.HistogramVec)
+		if !ok {
+			return err
+		}
+
+		t.mu.Lock()
+		t.Phases = existing
+		t.mu.Unlock()
+
+		return nil
+	}
+
+	return err
+}
+
+func (t *Transport) phases() *prometheus.HistogramVec {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.Phases
+}
+
+// RoundTrip sends r with the next RoundTripper and records it.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	c := call{
+		method:    r.Method,
+		host:      r.URL.Host,
+		operation: operationFrom(r.Context()),
+	}
+
+	if phases := t.phases(); phases != nil {
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), trace(phases, c.host, start)))
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		c.failed = true
+		c.errorClass = errorClass(err)
+	} else {
+		c.status = resp.StatusCode
+		c.failed = t.opts.isError(resp.StatusCode)
+		if c.failed {
+			c.errorClass = statusClass(resp.StatusCode)
+		}
+	}
+
+	t.record(c, time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// trace returns the httptrace hooks observing the phases of a request into
+// phases.
+func trace(phases *prometheus.HistogramVec, host string, start time.Time) *httptrace.ClientTrace {
+	var (
+		mu                       sync.Mutex
+		dnsStart, connectStart   time.Time
+		tlsStart                 time.Time
+		connected, gotFirstBytes bool
+	)
+
+	observe := func(phase string, since time.Time) {
+		phases.WithLabelValues(host, phase).Observe(time.Since(since).Seconds())
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			observe("dns", dnsStart)
+		},
+		// Dialing may race several addresses: the phase lasts from the
+		// first attempt to the first successful connection.
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && !connected {
+				connected = true
+				observe("connect", connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			observe("tls", tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !gotFirstBytes {
+				gotFirstBytes = true
+				observe("first_byte", start)
+			}
+		},
+	}
+}
+
+// errorClass classifies a transport error into a bounded set of classes.
+func errorClass(err error) string {
+	var (
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		opErr        *net.OpError
+		recordErr    tls.RecordHeaderError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &recordErr),
+		errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return "tls"
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return "connect"
+	default:
+		return "other"
+	}
+}
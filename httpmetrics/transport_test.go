@@ -0,0 +1,246 @@
+package httpmetrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClientRED(t *testing.T) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "client",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"host", "method", "operation", "status_class"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: []string{"operation", "error_class"},
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"operation", "outcome"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func get(t *testing.T, client *http.Client, ctx context.Context, target string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+func TestREDTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/boom" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	red := newTestClientRED(t)
+	transport, err := NewREDTransport(red, TransportOpts{Trace: true})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	ctx := WithOperation(context.Background(), "get-user")
+	get(t, client, ctx, srv.URL+"/users/1")
+	get(t, client, ctx, srv.URL+"/boom")
+	get(t, client, context.Background(), srv.URL+"/users/2")
+
+	host := srv.Listener.Addr().String()
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(host, "GET", "get-user", "2xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(host, "GET", "get-user", "5xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(host, "GET", "unknown", "2xx")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("get-user", "5xx")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Errors))
+	assert.Equal(t, 3, testutil.CollectAndCount(red.Duration.Histogram))
+
+	// The first request connected, every request got a first byte.
+	assert.Equal(t, 2, testutil.CollectAndCount(transport.Phases))
+}
+
+func TestREDTransportErrors(t *testing.T) {
+	t.Parallel()
+
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	slow := make(chan struct{})
+	slowSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-slow
+	}))
+	defer slowSrv.Close()
+	defer close(slow)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	red := newTestClientRED(t)
+	transport, err := NewREDTransport(red, TransportOpts{Next: &http.Transport{}})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	get(t, client, WithOperation(context.Background(), "tls"), tlsSrv.URL)
+	get(t, client, WithOperation(context.Background(), "connect"), closedURL)
+
+	ctx, cancel := context.WithTimeout(WithOperation(context.Background(), "timeout"), 10*time.Millisecond)
+	defer cancel()
+	get(t, client, ctx, slowSrv.URL)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("tls", "tls")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("connect", "connect")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("timeout", "timeout")))
+
+	u, err := url.Parse(closedURL)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(u.Host, "GET", "connect", "none")))
+	assert.Nil(t, transport.Phases)
+	assert.NoError(t, transport.Register())
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestTransportRegisterSharesPhases(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer users.Close()
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer orders.Close()
+
+	// Two clients of the same service, traced in the same namespace.
+	red := newTestClientRED(t)
+	first, err := NewREDTransport(red, TransportOpts{Next: &http.Transport{}, Trace: true})
+	assert.NoError(t, err)
+	second, err := NewREDTransport(red, TransportOpts{Next: &http.Transport{}, Trace: true})
+	assert.NoError(t, err)
+
+	assert.NoError(t, first.Register())
+	assert.NoError(t, second.Register())
+	assert.Same(t, first.Phases, second.Phases)
+
+	get(t, &http.Client{Transport: first}, context.Background(), users.URL)
+	get(t, &http.Client{Transport: second}, context.Background(), orders.URL)
+
+	// Both hosts connected and got a first byte.
+	assert.Equal(t, 4, testutil.CollectAndCount(registry, "client_http_client_phase_duration_seconds"))
+}
+
+func TestFGSTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: "client",
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   "http_client_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "Outbound HTTP request latency in seconds",
+			LatencyLabels: []string{"operation", "outcome"},
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   "http_client_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Total number of outbound HTTP requests",
+			TrafficLabels: []string{"operation", "status"},
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"operation", "error_class"},
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   "http_client_saturation_ratio",
+			SaturationHelp:   "Ratio of in-flight requests to capacity",
+			SaturationLabels: []string{"client"},
+		},
+	})
+	assert.NoError(t, err)
+
+	transport, err := NewFGSTransport(fgs, TransportOpts{
+		IsError: func(status int) bool { return status >= 400 },
+	})
+	assert.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	get(t, client, WithOperation(context.Background(), "list"), srv.URL)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("list", "502")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Errors.WithLabelValues("list", "5xx")))
+	assert.Equal(t, 1, testutil.CollectAndCount(fgs.Latency.Histogram))
+}
+
+func TestTransportUnsupportedLabel(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewREDTransport(newTestRED(t, "route"), TransportOpts{})
+	assert.Error(t, err)
+}
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err  error
+		want string
+	}{
+		"canceled":      {err: fmt.Errorf("get: %w", context.Canceled), want: "canceled"},
+		"deadline":      {err: fmt.Errorf("get: %w", context.DeadlineExceeded), want: "timeout"},
+		"dns":           {err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nope.invalid"}}, want: "dns"},
+		"net timeout":   {err: &net.OpError{Op: "read", Err: timeoutError{}}, want: "timeout"},
+		"unknown ca":    {err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, want: "tls"},
+		"hostname":      {err: x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}, want: "tls"},
+		"record header": {err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, want: "tls"},
+		"connect":       {err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: "connect"},
+		"anything else": {err: errors.New("boom"), want: "other"},
+		"read not dial": {err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: "other"},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorClass(tt.err))
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,134 @@
+package httpmetrics
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter captures the status of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the first final status. Informational 1xx statuses,
+// such as 103 Early Hints, precede the final one and are ignored like
+// net/http does, except for 101 Switching Protocols, which is final.
+func (w *responseWriter) WriteHeader(code int) {
+	informational := code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols
+	if !w.wroteHeader && !informational {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.implicitHeader()
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status written, or http.StatusOK, which net/http sends
+// when a handler never calls WriteHeader.
+func (w *responseWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// implicitHeader records the status net/http sends when a handler writes a
+// body without calling WriteHeader first.
+func (w *responseWriter) implicitHeader() {
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+}
+
+type flusher struct{ w *responseWriter }
+
+func (f flusher) Flush() {
+	f.w.implicitHeader()
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct{ w *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type readerFrom struct{ w *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.w.implicitHeader()
+
+	return r.w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+}
+
+// wrap returns a writer capturing the status of w that implements the same
+// optional interfaces as w out of http.Flusher, http.Hijacker and
+// io.ReaderFrom, so handlers relying on them keep working.
+func wrap(w http.ResponseWriter) (http.ResponseWriter, *responseWriter) {
+	rw := &responseWriter{ResponseWriter: w}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isReaderFrom := w.(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, flusher{rw}, hijacker{rw}, readerFrom{rw}}, rw
+	case isFlusher && isHijacker:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, flusher{rw}, hijacker{rw}}, rw
+	case isFlusher && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, flusher{rw}, readerFrom{rw}}, rw
+	case isHijacker && isReaderFrom:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, hijacker{rw}, readerFrom{rw}}, rw
+	case isFlusher:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{rw, flusher{rw}}, rw
+	case isHijacker:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, hijacker{rw}}, rw
+	case isReaderFrom:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{rw, readerFrom{rw}}, rw
+	default:
+		return rw, rw
+	}
+}
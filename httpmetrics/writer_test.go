@@ -0,0 +1,162 @@
+package httpmetrics
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+
+	return nil, nil, nil
+}
+
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom = true
+
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+// plainWriter hides the optional interfaces of the recorder.
+type plainWriter struct {
+	w http.ResponseWriter
+}
+
+func (p plainWriter) Header() http.Header         { return p.w.Header() }
+func (p plainWriter) Write(b []byte) (int, error) { return p.w.Write(b) }
+func (p plainWriter) WriteHeader(code int)        { p.w.WriteHeader(code) }
+
+func TestWrapPreservesInterfaces(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		writer                                    http.ResponseWriter
+		wantFlusher, wantHijacker, wantReaderFrom bool
+	}{
+		"plain": {
+			writer: plainWriter{httptest.NewRecorder()},
+		},
+		"flusher": {
+			writer:      httptest.NewRecorder(),
+			wantFlusher: true,
+		},
+		"flusher and hijacker": {
+			writer:       &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()},
+			wantFlusher:  true,
+			wantHijacker: true,
+		},
+		"flusher and reader from": {
+			writer:         &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()},
+			wantFlusher:    true,
+			wantReaderFrom: true,
+		},
+		"all": {
+			writer: &struct {
+				*hijackableRecorder
+				io.ReaderFrom
+			}{
+				&hijackableRecorder{ResponseRecorder: httptest.NewRecorder()},
+				&readerFromRecorder{ResponseRecorder: httptest.NewRecorder()},
+			},
+			wantFlusher:    true,
+			wantHijacker:   true,
+			wantReaderFrom: true,
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+
+		t.Run(name, func(t *testing.T) {
+			w, _ := wrap(tt.writer)
+
+			_, isFlusher := w.(http.Flusher)
+			_, isHijacker := w.(http.Hijacker)
+			_, isReaderFrom := w.(io.ReaderFrom)
+
+			assert.Equal(t, tt.wantFlusher, isFlusher)
+			assert.Equal(t, tt.wantHijacker, isHijacker)
+			assert.Equal(t, tt.wantReaderFrom, isReaderFrom)
+		})
+	}
+}
+
+func TestWrapCapturesStatus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default", func(t *testing.T) {
+		_, rw := wrap(httptest.NewRecorder())
+		assert.Equal(t, http.StatusOK, rw.Status())
+	})
+
+	t.Run("first write header wins", func(t *testing.T) {
+		w, rw := wrap(httptest.NewRecorder())
+		w.WriteHeader(http.StatusTeapot)
+		w.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(t, http.StatusTeapot, rw.Status())
+	})
+
+	t.Run("informational status", func(t *testing.T) {
+		w, rw := wrap(httptest.NewRecorder())
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(t, http.StatusInternalServerError, rw.Status())
+	})
+
+	t.Run("switching protocols", func(t *testing.T) {
+		w, rw := wrap(httptest.NewRecorder())
+		w.WriteHeader(http.StatusSwitchingProtocols)
+		w.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(t, http.StatusSwitchingProtocols, rw.Status())
+	})
+
+	t.Run("write after write header", func(t *testing.T) {
+		w, rw := wrap(httptest.NewRecorder())
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("missing"))
+		assert.Equal(t, http.StatusNotFound, rw.Status())
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		w, rw := wrap(rec)
+		w.(http.Flusher).Flush()
+		w.WriteHeader(http.StatusInternalServerError)
+		assert.Equal(t, http.StatusOK, rw.Status())
+		assert.True(t, rec.Flushed)
+	})
+
+	t.Run("hijack", func(t *testing.T) {
+		rec := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w, _ := wrap(rec)
+		_, _, _ = w.(http.Hijacker).Hijack()
+		assert.True(t, rec.hijacked)
+	})
+
+	t.Run("read from", func(t *testing.T) {
+		rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w, rw := wrap(rec)
+		_, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("body"))
+		assert.NoError(t, err)
+		assert.True(t, rec.readFrom)
+		assert.Equal(t, http.StatusOK, rw.Status())
+		assert.Equal(t, "body", rec.Body.String())
+	})
+}
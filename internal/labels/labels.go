@@ -0,0 +1,47 @@
+// Package labels derives the label values of a strategy's metrics from the
+// label names the strategy was created with.
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labeler returns the label values of a metric for a T, such as a request.
+type Labeler[T any] []func(T) string
+
+// New returns the Labeler of labels, whose values are looked up in values by
+// label name. It fails if a label is not in values.
+func New[T any](values map[string]func(T) string, labels []string) (Labeler[T], error) {
+	l := make(Labeler[T], 0, len(labels))
+	for _, name := range labels {
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("label %q is not one of %s", name, supported(values))
+		}
+		l = append(l, value)
+	}
+
+	return l, nil
+}
+
+// Values returns the label values for t.
+func (l Labeler[T]) Values(t T) []string {
+	values := make([]string, len(l))
+	for i, value := range l {
+		values[i] = value(t)
+	}
+
+	return values
+}
+
+func supported[T any](values map[string]func(T) string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
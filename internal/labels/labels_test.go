@@ -0,0 +1,37 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type call struct {
+	op     string
+	failed bool
+}
+
+var values = map[string]func(call) string{
+	"operation": func(c call) string { return c.op },
+	"failed": func(c call) string {
+		if c.failed {
+			return "true"
+		}
+		return "false"
+	},
+}
+
+func TestLabeler(t *testing.T) {
+	t.Parallel()
+
+	l, err := New(values, []string{"failed", "operation"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"true", "refresh"}, l.Values(call{op: "refresh", failed: true}))
+
+	empty, err := New(values, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Values(call{}))
+
+	_, err = New(values, []string{"operation", "tenant"})
+	assert.EqualError(t, err, `label "tenant" is not one of failed, operation`)
+}
@@ -0,0 +1,360 @@
+// Package queue is a buffered channel whose items drive a USE strategy:
+//
+//	Utilization  queued items over capacity
+//	Saturation   enqueues that found the queue full, blocked or rejected
+//	Errors       items dropped, by reason
+//
+// Saturation is a running total, only reset with the process, so its rate is
+// the rate of enqueues the queue had no room for.
+//
+// The Queue also records how long blocked enqueues waited for room in its
+// EnqueueWait distribution, whose count is the number of enqueues that
+// blocked, and how long items stayed queued in its Dwell distribution.
+//
+// The label values of every metric are derived from the label names the
+// strategy was created with:
+//
+//	queue, name              the name of the queue
+//	reason, error, class     why an item was dropped, "" otherwise
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+var (
+	// ErrFull is returned by TryEnqueue and EnqueueTimeout when the queue has
+	// no room for the item.
+	ErrFull = errors.New("queue: full")
+	// ErrClosed is returned by enqueues once the queue is closed, and by
+	// Dequeue once it is closed and empty.
+	ErrClosed = errors.New("queue: closed")
+)
+
+// The reasons an item is dropped.
+const (
+	// ReasonFull is an item TryEnqueue found no room for.
+	ReasonFull = "full"
+	// ReasonTimeout is an item that found no room before the timeout of
+	// EnqueueTimeout, or the deadline of the context of Enqueue.
+	ReasonTimeout = "timeout"
+	// ReasonCanceled is an item whose Enqueue context was canceled.
+	ReasonCanceled = "canceled"
+	// ReasonClosed is an item enqueued once the queue is closed.
+	ReasonClosed = "closed"
+)
+
+// Opts is the options to create a Queue.
+type Opts struct {
+	// Name is the name of the queue, the value of the queue label.
+	Name string `validate:"required"`
+	// Capacity is the number of items the queue holds.
+	Capacity int `validate:"gt=0"`
+	// WaitBuckets defines the buckets of the EnqueueWait histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	WaitBuckets []float64
+	// DwellBuckets defines the buckets of the Dwell histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	DwellBuckets []float64
+}
+
+// state holds what label values are derived from.
+type state struct {
+	queue  string
+	reason string
+}
+
+var labelValues = map[string]func(state) string{
+	"queue":  func(s state) string { return s.queue },
+	"name":   func(s state) string { return s.queue },
+	"reason": func(s state) string { return s.reason },
+	"error":  func(s state) string { return s.reason },
+	"class":  func(s state) string { return s.reason },
+}
+
+// item is a queued value.
+type item[T any] struct {
+	value    T
+	enqueued time.Time
+}
+
+// Queue is a bounded FIFO of T.
+type Queue[T any] struct {
+	// EnqueueWait is how long blocked enqueues waited for room in seconds,
+	// {namespace}_queue_enqueue_wait_seconds, labelled by queue name.
+	// Once registered, it is shared by every queue of the namespace.
+	EnqueueWait *strategy.Distribution
+	// Dwell is how long items stayed queued in seconds, from the start of
+	// their enqueue, {namespace}_queue_dwell_seconds, labelled by queue
+	// name. Once registered, it is shared by every queue of the namespace.
+	Dwell *strategy.Distribution
+
+	use               *strategy.USE
+	name              string
+	utilizationLabels []string
+	saturationLabels  []string
+	errorLabels       labels.Labeler[state]
+
+	items chan item[T]
+	quit  chan struct{}
+
+	senders sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	// full is the number of enqueues that found the queue full.
+	full int
+}
+
+// New creates a Queue. It fails if a label of the strategy is not supported.
+func New[T any](use *strategy.USE, opts Opts) (*Queue[T], error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	o := use.Opts()
+
+	utilizationLabels, err := labels.New(labelValues, o.UtilizationOpt.UtilizationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	saturationLabels, err := labels.New(labelValues, o.SaturationOpt.SaturationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	wait, err := strategy.NewDistribution(strategy.DistributionOpts{
+		Namespace: o.Namespace,
+		Name:      "queue_enqueue_wait_seconds",
+		Help:      "Time blocked enqueues waited for room in seconds",
+		Labels:    []string{"queue"},
+		Buckets:   opts.WaitBuckets,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dwell, err := strategy.NewDistribution(strategy.DistributionOpts{
+		Namespace: o.Namespace,
+		Name:      "queue_dwell_seconds",
+		Help:      "Time items stayed queued in seconds",
+		Labels:    []string{"queue"},
+		Buckets:   opts.DwellBuckets,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue[T]{
+		EnqueueWait:       wait,
+		Dwell:             dwell,
+		use:               use,
+		name:              opts.Name,
+		utilizationLabels: utilizationLabels.Values(state{queue: opts.Name}),
+		saturationLabels:  saturationLabels.Values(state{queue: opts.Name}),
+		errorLabels:       errorLabels,
+		items:             make(chan item[T], opts.Capacity),
+		quit:              make(chan struct{}),
+	}
+
+	q.update(0)
+
+	return q, nil
+}
+
+// Register registers the EnqueueWait and Dwell distributions with the
+// Prometheus DefaultRegisterer, or shares the ones another queue of the
+// namespace registered. The strategy is registered on its own.
+func (q *Queue[T]) Register() error {
+	wait, err := q.EnqueueWait.RegisterShared()
+	if err != nil {
+		return err
+	}
+
+	dwell, err := q.Dwell.RegisterShared()
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.EnqueueWait = wait
+	q.Dwell = dwell
+	q.mu.Unlock()
+
+	return nil
+}
+
+// distributions returns EnqueueWait and Dwell, which Register replaces.
+func (q *Queue[T]) distributions() (wait, dwell *strategy.Distribution) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.EnqueueWait, q.Dwell
+}
+
+// Enqueue adds v to the queue, blocking while it is full. It fails with the
+// error of ctx if ctx is done first.
+func (q *Queue[T]) Enqueue(ctx context.Context, v T) error {
+	return q.enqueue(ctx, v, true)
+}
+
+// TryEnqueue adds v to the queue if it has room, and fails with ErrFull
+// otherwise.
+func (q *Queue[T]) TryEnqueue(v T) error {
+	return q.enqueue(context.Background(), v, false)
+}
+
+// EnqueueTimeout adds v to the queue, blocking while it is full for at most
+// timeout. It fails with ErrFull if the queue had no room in time.
+func (q *Queue[T]) EnqueueTimeout(v T, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := q.enqueue(ctx, v, true)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrFull
+	}
+
+	return err
+}
+
+// enqueue adds v to the queue. Unless block, an item the queue has no room
+// for is dropped as full. Otherwise enqueue blocks until there is room or
+// ctx is done.
+func (q *Queue[T]) enqueue(ctx context.Context, v T, block bool) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.drop(ReasonClosed)
+
+		return ErrClosed
+	}
+	q.senders.Add(1)
+	q.mu.Unlock()
+
+	defer q.senders.Done()
+
+	it := item[T]{value: v, enqueued: time.Now()}
+
+	select {
+	case q.items <- it:
+		q.update(0)
+
+		return nil
+	default:
+	}
+
+	// The queue is full: the enqueue is rejected or blocks.
+	q.update(1)
+
+	if !block {
+		q.drop(ReasonFull)
+
+		return ErrFull
+	}
+
+	start := time.Now()
+	defer func() {
+		wait, _ := q.distributions()
+		wait.Observe(time.Since(start).Seconds(), q.name)
+	}()
+
+	select {
+	case q.items <- it:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			q.drop(ReasonTimeout)
+		} else {
+			q.drop(ReasonCanceled)
+		}
+
+		return ctx.Err()
+	case <-q.quit:
+		q.drop(ReasonClosed)
+
+		return ErrClosed
+	}
+}
+
+// Dequeue removes the oldest item of the queue, blocking while it is empty.
+// It fails with the error of ctx if ctx is done first, and with ErrClosed
+// once the queue is closed and empty.
+func (q *Queue[T]) Dequeue(ctx context.Context) (T, error) {
+	select {
+	case it, ok := <-q.items:
+		if !ok {
+			var zero T
+
+			return zero, ErrClosed
+		}
+
+		_, dwell := q.distributions()
+		dwell.Observe(time.Since(it.enqueued).Seconds(), q.name)
+		q.update(0)
+
+		return it.value, nil
+	case <-ctx.Done():
+		var zero T
+
+		return zero, ctx.Err()
+	}
+}
+
+// Close stops the queue from accepting items. Blocked enqueues fail with
+// ErrClosed, and the items already queued can still be dequeued.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+
+		return
+	}
+	q.closed = true
+	close(q.quit)
+	q.mu.Unlock()
+
+	// Once every enqueue returned, nothing is sent anymore.
+	q.senders.Wait()
+	close(q.items)
+}
+
+// Len returns the number of queued items.
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Cap returns the capacity of the queue.
+func (q *Queue[T]) Cap() int {
+	return cap(q.items)
+}
+
+func (q *Queue[T]) drop(reason string) {
+	q.use.ObserveError(q.errorLabels.Values(state{queue: q.name, reason: reason})...)
+}
+
+// update adds full to the enqueues that found the queue full and sets
+// utilization and saturation.
+func (q *Queue[T]) update(full int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.full += full
+
+	q.use.SetUtilization(float64(len(q.items))/float64(cap(q.items)), q.utilizationLabels...)
+	q.use.SetSaturation(float64(q.full), q.saturationLabels...)
+}
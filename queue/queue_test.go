@@ -0,0 +1,238 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUSE(t *testing.T, errorLabels ...string) *strategy.USE {
+	t.Helper()
+
+	use, err := strategy.NewUSE(strategy.USEOpts{
+		Namespace: "pipeline",
+		UtilizationOpt: strategy.USEUtilizationOpt{
+			UtilizationName:   "queue_utilization_ratio",
+			UtilizationHelp:   "Queued items over capacity",
+			UtilizationLabels: []string{"queue"},
+		},
+		SaturationOpt: strategy.USESaturationOpt{
+			SaturationName:   "queue_saturation_full_enqueues",
+			SaturationHelp:   "Enqueues that found the queue full",
+			SaturationLabels: []string{"name"},
+		},
+		ErrorsOpt: strategy.USEErrorsOpt{
+			ErrorLabels: errorLabels,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return use
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		labels []string
+		opts   Opts
+		err    string
+	}{
+		"valid": {
+			labels: []string{"queue", "reason"},
+			opts:   Opts{Name: "events", Capacity: 2},
+		},
+		"missing name": {
+			labels: []string{"queue"},
+			opts:   Opts{Capacity: 2},
+			err:    "Opts.Name",
+		},
+		"no capacity": {
+			labels: []string{"queue"},
+			opts:   Opts{Name: "events"},
+			err:    "Opts.Capacity",
+		},
+		"unsupported label": {
+			labels: []string{"queue", "tenant"},
+			opts:   Opts{Name: "events", Capacity: 2},
+			err:    `label "tenant" is not one of`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			q, err := New[int](newTestUSE(t, tc.labels...), tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 2, q.Cap())
+		})
+	}
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "queue", "reason")
+	q, err := New[string](use, Opts{Name: "events", Capacity: 2})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	assert.NoError(t, q.Enqueue(ctx, "a"))
+	assert.Equal(t, 0.5, testutil.ToFloat64(use.Utilization.WithLabelValues("events")))
+	assert.NoError(t, q.TryEnqueue("b"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Utilization.WithLabelValues("events")))
+	assert.ErrorIs(t, q.TryEnqueue("c"), ErrFull)
+	assert.Equal(t, 2, q.Len())
+
+	v, err := q.Dequeue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", v)
+	assert.Equal(t, 0.5, testutil.ToFloat64(use.Utilization.WithLabelValues("events")))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues("events", ReasonFull)))
+	// The rejected enqueue counts into saturation.
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Saturation.WithLabelValues("events")))
+	assert.Equal(t, 1, testutil.CollectAndCount(q.Dwell.Histogram))
+	assert.Equal(t, 0, testutil.CollectAndCount(q.EnqueueWait.Histogram))
+}
+
+func TestEnqueueBlocks(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "reason")
+	q, err := New[int](use, Opts{Name: "events", Capacity: 1})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, q.Enqueue(ctx, 1))
+
+	enqueued := make(chan error)
+	go func() { enqueued <- q.Enqueue(ctx, 2) }()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(use.Saturation.WithLabelValues("events")) == 1
+	}, time.Second, time.Millisecond)
+
+	v, err := q.Dequeue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.NoError(t, <-enqueued)
+
+	// Saturation is a running total: the blocked enqueue stays counted.
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Saturation.WithLabelValues("events")))
+	assert.Equal(t, 1, testutil.CollectAndCount(q.EnqueueWait.Histogram))
+
+	v, err = q.Dequeue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestEnqueueExpires(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "class")
+	q, err := New[int](use, Opts{Name: "events", Capacity: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.TryEnqueue(1))
+
+	assert.ErrorIs(t, q.EnqueueTimeout(2, time.Millisecond), ErrFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Enqueue(ctx, 3), context.DeadlineExceeded)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, q.Enqueue(canceled, 4), context.Canceled)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(use.Errors.WithLabelValues(ReasonTimeout)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues(ReasonCanceled)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(use.Saturation.WithLabelValues("events")))
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "error")
+	q, err := New[int](use, Opts{Name: "events", Capacity: 1})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, q.Enqueue(ctx, 1))
+
+	blocked := make(chan error)
+	go func() { blocked <- q.Enqueue(ctx, 2) }()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(use.Saturation.WithLabelValues("events")) == 1
+	}, time.Second, time.Millisecond)
+
+	q.Close()
+	q.Close()
+
+	assert.ErrorIs(t, <-blocked, ErrClosed)
+	assert.ErrorIs(t, q.TryEnqueue(3), ErrClosed)
+
+	v, err := q.Dequeue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	_, err = q.Dequeue(ctx)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(use.Errors.WithLabelValues(ReasonClosed)))
+}
+
+func TestDequeueCanceled(t *testing.T) {
+	t.Parallel()
+
+	q, err := New[int](newTestUSE(t, "reason"), Opts{Name: "events", Capacity: 1})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	_, err = q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	use := newTestUSE(t, "queue")
+
+	// Queues of a namespace share the EnqueueWait and Dwell distributions.
+	for _, name := range []string{"a", "b"} {
+		q, err := New[int](use, Opts{Name: name, Capacity: 1})
+		assert.NoError(t, err)
+		assert.NoError(t, q.Register())
+
+		assert.NoError(t, q.TryEnqueue(1))
+		_, err = q.Dequeue(context.Background())
+		assert.NoError(t, err)
+	}
+
+	count, err := testutil.GatherAndCount(registry, "pipeline_queue_dwell_seconds_hist")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
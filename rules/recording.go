@@ -0,0 +1,147 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rabellamy/promstrap/collectors"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// RED generates the recording rules of a RED strategy: the rate of requests
+// and errors, the error ratio (errors / requests) and the request duration
+// quantiles from the duration histogram.
+func RED(red *strategy.RED, opts Opts) RuleGroup {
+	o := red.Opts()
+	requests := fqName(o.Namespace, red.RequestMetricName())
+	errors := fqName(o.Namespace, red.ErrorMetricName())
+	duration := fqName(o.Namespace, red.Duration.HistogramName())
+
+	var rules []Rule
+	rules = append(rules, rateRules(requests, o.RequestsOpt.RequestLabels, opts)...)
+	rules = append(rules, rateRules(errors, o.ErrorsOpt.ErrorLabels, opts)...)
+	rules = append(rules, ratioRules(errors, o.ErrorsOpt.ErrorLabels, requests, o.RequestsOpt.RequestLabels, opts)...)
+	rules = append(rules, quantileRules(duration, o.DurationOpt.DurationLabels, opts)...)
+
+	return opts.group(requests+".red", rules)
+}
+
+// USE generates the recording rules of a USE strategy: the average
+// utilization and saturation, and the rate of errors.
+func USE(use *strategy.USE, opts Opts) RuleGroup {
+	o := use.Opts()
+	utilization := fqName(o.Namespace, use.UtilizationMetricName())
+	saturation := fqName(o.Namespace, use.SaturationMetricName())
+	errors := fqName(o.Namespace, use.ErrorMetricName())
+
+	var rules []Rule
+	rules = append(rules, averageRules(utilization, o.UtilizationOpt.UtilizationLabels, opts)...)
+	rules = append(rules, averageRules(saturation, o.SaturationOpt.SaturationLabels, opts)...)
+	rules = append(rules, rateRules(errors, o.ErrorsOpt.ErrorLabels, opts)...)
+
+	return opts.group(utilization+".use", rules)
+}
+
+// FGS generates the recording rules of a FourGoldenSignals strategy: the
+// rate of traffic and errors, the error ratio (errors / traffic), the latency
+// quantiles from the latency histogram and the average saturation.
+func FGS(fgs *strategy.FourGoldenSignals, opts Opts) RuleGroup {
+	o := fgs.Opts()
+	latency := fqName(o.Namespace, fgs.Latency.HistogramName())
+	traffic := fqName(o.Namespace, fgs.TrafficMetricName())
+	errors := fqName(o.Namespace, fgs.ErrorMetricName())
+	saturation := fqName(o.Namespace, fgs.SaturationMetricName())
+
+	var rules []Rule
+	rules = append(rules, rateRules(traffic, o.TrafficOpt.TrafficLabels, opts)...)
+	rules = append(rules, rateRules(errors, o.ErrorsOpt.ErrorLabels, opts)...)
+	rules = append(rules, ratioRules(errors, o.ErrorsOpt.ErrorLabels, traffic, o.TrafficOpt.TrafficLabels, opts)...)
+	rules = append(rules, quantileRules(latency, o.LatencyOpt.LatencyLabels, opts)...)
+	rules = append(rules, averageRules(saturation, o.SaturationOpt.SaturationLabels, opts)...)
+
+	return opts.group(traffic+".fgs", rules)
+}
+
+// Apdex generates the recording rules of an Apdex strategy: the Apdex score
+// computed from the T and 4T buckets of the duration histogram, (satisfied +
+// tolerating / 2) / total.
+func Apdex(apdex *strategy.Apdex, opts Opts) RuleGroup {
+	o := apdex.Opts()
+	duration := fqName(o.Namespace, apdex.Duration.HistogramName())
+	score := fqName(o.Namespace, strings.TrimSuffix(apdex.ScoreMetricName(), "_score"))
+	labels := by(o.Labels...)
+
+	bucket := func(le float64, w time.Duration) string {
+		return sumBy(labels, fmt.Sprintf("rate(%s_bucket{le=%q}[%s])", duration, leValue(le), window(w)))
+	}
+
+	rules := make([]Rule, 0, len(opts.windows()))
+	for _, w := range opts.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(labels, score, "ratio_rate"+window(w)),
+			Expr: fmt.Sprintf("(\n%s\n+\n%s\n) / 2\n/\n%s",
+				bucket(o.Target, w),
+				bucket(4*o.Target, w),
+				sumBy(labels, fmt.Sprintf("rate(%s_count[%s])", duration, window(w)))),
+		})
+	}
+
+	return opts.group(score+".apdex", rules)
+}
+
+// Cache generates the recording rules of a Cache strategy: the rate of
+// lookups by result and of evictions by reason, the hit ratio (hits /
+// lookups, errors included), the load duration quantiles from the load
+// duration histogram and the average utilization.
+func Cache(cache *strategy.Cache, opts Opts) RuleGroup {
+	o := cache.Opts()
+	lookups := fqName(o.Namespace, cache.LookupsMetricName())
+	evictions := fqName(o.Namespace, cache.EvictionsMetricName())
+	loadDuration := fqName(o.Namespace, cache.LoadDuration.HistogramName())
+	utilization := fqName(o.Namespace, cache.UtilizationMetricName())
+	prefix := strings.TrimSuffix(counterBase(lookups), "_lookups")
+	labels := by(o.Labels...)
+
+	var rules []Rule
+	rules = append(rules, rateRules(lookups, append(append([]string(nil), o.Labels...), "result"), opts)...)
+	rules = append(rules, rateRules(evictions, append(append([]string(nil), o.Labels...), "reason"), opts)...)
+	for _, w := range opts.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(labels, prefix+"_hit", "ratio_rate"+window(w)),
+			Expr: fmt.Sprintf("%s\n/\n%s",
+				sumBy(labels, fmt.Sprintf("rate(%s{result=%q}[%s])", lookups, strategy.CacheHit, window(w))),
+				sumBy(labels, fmt.Sprintf("rate(%s[%s])", lookups, window(w)))),
+		})
+	}
+	rules = append(rules, quantileRules(loadDuration, o.Labels, opts)...)
+	rules = append(rules, averageRules(utilization, o.Labels, opts)...)
+
+	return opts.group(prefix+".cache", rules)
+}
+
+// Runtime generates the recording rules of a RuntimeCollector: the CPU
+// utilization by resource (rate of CPU time / GOMAXPROCS), the scheduler
+// latency quantiles, the average runnable goroutines and memory limit ratio,
+// and the rate of GC cycles.
+func Runtime(c *collectors.RuntimeCollector, opts Opts) RuleGroup {
+	prefix := fqName(c.Opts().Namespace, "runtime")
+	cpu := prefix + "_cpu_seconds_total"
+	capacity := prefix + "_cpu_capacity_cores"
+	labels := by("resource")
+
+	var rules []Rule
+	for _, w := range opts.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(labels, prefix+"_cpu_utilization", "ratio_rate"+window(w)),
+			Expr: fmt.Sprintf("avg by (%s) (\nrate(%s[%s])\n/ ignoring (resource) group_left\n%s\n)",
+				strings.Join(labels, ", "), cpu, window(w), capacity),
+		})
+	}
+	rules = append(rules, quantileRules(prefix+"_saturation_sched_latency_seconds", nil, opts)...)
+	rules = append(rules, averageRules(prefix+"_saturation_runnable_goroutines", nil, opts)...)
+	rules = append(rules, averageRules(prefix+"_saturation_memory_limit_ratio", nil, opts)...)
+	rules = append(rules, rateRules(prefix+"_errors_total", []string{"reason"}, opts)...)
+
+	return opts.group(prefix+".runtime", rules)
+}
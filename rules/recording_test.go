@@ -0,0 +1,250 @@
+package rules
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rabellamy/promstrap/collectors"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(want), string(got))
+}
+
+func newTestRED(t *testing.T) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "service",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"path", "verb"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: []string{"path", "error"},
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"path"},
+			Buckets:        []float64{0.05, 0.1, 0.25, 0.5, 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func newTestUSE(t *testing.T) *strategy.USE {
+	t.Helper()
+
+	use, err := strategy.NewUSE(strategy.USEOpts{
+		Namespace: "system",
+		UtilizationOpt: strategy.USEUtilizationOpt{
+			UtilizationName:   "memory_utilization_ratio",
+			UtilizationHelp:   "Memory utilization as a ratio of used to total",
+			UtilizationLabels: []string{"type"},
+		},
+		SaturationOpt: strategy.USESaturationOpt{
+			SaturationName:   "memory_saturation_bytes",
+			SaturationHelp:   "Amount of memory queued/waiting to be freed",
+			SaturationLabels: []string{"type"},
+		},
+		ErrorsOpt: strategy.USEErrorsOpt{
+			ErrorLabels: []string{"type"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return use
+}
+
+func newTestFGS(t *testing.T) *strategy.FourGoldenSignals {
+	t.Helper()
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: "service",
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   "http_request_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "HTTP request latency in seconds",
+			LatencyLabels: []string{"method", "path"},
+			Buckets:       []float64{.005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   "http_server_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Total number of HTTP requests",
+			TrafficLabels: []string{"method", "path", "status"},
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"method", "path", "status"},
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   "memory_heap_saturation_bytes",
+			SaturationHelp:   "Memory heap usage in bytes",
+			SaturationLabels: []string{"gc_type"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fgs
+}
+
+func newTestApdex(t *testing.T) *strategy.Apdex {
+	t.Helper()
+
+	apdex, err := strategy.NewApdex(strategy.ApdexOpts{
+		Namespace: "service",
+		Target:    0.3,
+		Labels:    []string{"path"},
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return apdex
+}
+
+func newTestCache(t *testing.T) *strategy.Cache {
+	t.Helper()
+
+	cache, err := strategy.NewCache(strategy.CacheOpts{
+		Namespace: "service",
+		Labels:    []string{"cache"},
+		Buckets:   []float64{0.01, 0.1, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cache
+}
+
+func newTestRuntime(t *testing.T) *collectors.RuntimeCollector {
+	t.Helper()
+
+	c, err := collectors.NewRuntimeCollector(collectors.RuntimeOpts{Namespace: "service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestRecordingRulesGolden(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+	use := newTestUSE(t)
+	fgs := newTestFGS(t)
+	apdex := newTestApdex(t)
+	cache := newTestCache(t)
+	runtimeUSE := newTestRuntime(t)
+
+	tests := map[string]struct {
+		group RuleGroup
+		file  string
+	}{
+		"red": {
+			group: RED(red, Opts{}),
+			file:  "testdata/red.yaml",
+		},
+		"red with windows": {
+			group: RED(red, Opts{
+				GroupName: "api",
+				Interval:  time.Minute,
+				Windows:   []time.Duration{5 * time.Minute, time.Hour},
+				Quantiles: []float64{0.999, 0.5},
+			}),
+			file: "testdata/red_windows.yaml",
+		},
+		"use": {
+			group: USE(use, Opts{}),
+			file:  "testdata/use.yaml",
+		},
+		"fgs": {
+			group: FGS(fgs, Opts{}),
+			file:  "testdata/fgs.yaml",
+		},
+		"apdex": {
+			group: Apdex(apdex, Opts{}),
+			file:  "testdata/apdex.yaml",
+		},
+		"cache": {
+			group: Cache(cache, Opts{}),
+			file:  "testdata/cache.yaml",
+		},
+		"runtime": {
+			group: Runtime(runtimeUSE, Opts{}),
+			file:  "testdata/runtime.yaml",
+		},
+	}
+
+	for name, tt := range tests {
+		group := tt.group
+		file := tt.file
+
+		t.Run(name, func(t *testing.T) {
+			got, err := Marshal(group)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, file, got)
+		})
+	}
+}
+
+func TestRecordingRulesUseMetricNames(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+	group := RED(red, Opts{})
+
+	assert.Equal(t, "job_path_verb:service_http_requests:rate5m", group.Rules[0].Record)
+	assert.Contains(t, group.Rules[0].Expr, "service_"+red.RequestMetricName())
+	assert.Contains(t, group.Rules[1].Expr, "service_"+red.ErrorMetricName())
+	assert.Equal(t, "job_path:service_errors_per_service_http_requests:ratio_rate5m", group.Rules[2].Record)
+	assert.Contains(t, group.Rules[3].Expr, "service_"+red.Duration.HistogramName()+"_bucket")
+}
+
+func TestQuantileName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[float64]string{
+		0.5:   "p50",
+		0.99:  "p99",
+		0.29:  "p29",
+		0.999: "p999",
+	}
+
+	for q, want := range tests {
+		assert.Equal(t, want, quantileName(q))
+	}
+}
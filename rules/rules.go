@@ -0,0 +1,241 @@
+// Package rules generates Prometheus rule groups from strategies, so recording
+// and alerting rules stay in sync with the metric names and label sets the
+// strategies were created with.
+//
+// Recording rules follow the level:metric:operations naming convention.
+// https://prometheus.io/docs/practices/rules/
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a Prometheus rule file.
+type File struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// RuleGroup is a set of rules evaluated together at the same interval.
+type RuleGroup struct {
+	Name string `yaml:"name"`
+	// Interval is how often the rules are evaluated. If not specified, the
+	// global evaluation interval is used.
+	Interval model.Duration `yaml:"interval,omitempty"`
+	Rules    []Rule         `yaml:"rules"`
+}
+
+// Rule is either a recording rule or an alerting rule.
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         model.Duration    `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Marshal renders groups as a Prometheus rule file.
+func Marshal(groups ...RuleGroup) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(File{Groups: groups}); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Opts is the options to generate recording rules.
+type Opts struct {
+	// GroupName is the name of the rule group. If not specified, defaults to a
+	// name derived from the strategy and its primary metric.
+	GroupName string
+	// Interval is how often the rules are evaluated. If not specified, the
+	// global evaluation interval is used.
+	Interval time.Duration
+	// Windows are the range vector windows rates and averages are computed
+	// over. If not specified, defaults to 5m.
+	Windows []time.Duration
+	// Quantiles are the latency quantiles computed with histogram_quantile.
+	// If not specified, defaults to 0.5, 0.9 and 0.99.
+	Quantiles []float64
+}
+
+func (o Opts) windows() []time.Duration {
+	if o.Windows != nil {
+		return o.Windows
+	}
+
+	return []time.Duration{5 * time.Minute}
+}
+
+func (o Opts) quantiles() []float64 {
+	if o.Quantiles != nil {
+		return o.Quantiles
+	}
+
+	return []float64{0.5, 0.9, 0.99}
+}
+
+func (o Opts) group(defaultName string, rules []Rule) RuleGroup {
+	name := o.GroupName
+	if name == "" {
+		name = defaultName
+	}
+
+	return RuleGroup{
+		Name:     name,
+		Interval: model.Duration(o.Interval),
+		Rules:    rules,
+	}
+}
+
+// fqName returns the name a metric is exposed with.
+func fqName(namespace, name string) string {
+	return prometheus.BuildFQName(namespace, "", name)
+}
+
+// window formats a duration the way PromQL range selectors expect it.
+func window(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+// by returns the labels to aggregate by: job, followed by labels.
+func by(labels ...string) []string {
+	out := []string{"job"}
+	for _, l := range labels {
+		if !contains(out, l) {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
+
+// shared returns the labels present in both a and b, in the order of a.
+func shared(a, b []string) []string {
+	var out []string
+	for _, l := range a {
+		if contains(b, l) {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// level renders the aggregation level of a recording rule name.
+func level(labels []string) string {
+	return strings.Join(labels, "_")
+}
+
+// recordName renders a level:metric:operations recording rule name.
+func recordName(labels []string, metric, operations string) string {
+	return fmt.Sprintf("%s:%s:%s", level(labels), metric, operations)
+}
+
+// counterBase strips the _total suffix, as is customary once rate is applied.
+func counterBase(name string) string {
+	return strings.TrimSuffix(name, "_total")
+}
+
+func sumBy(labels []string, expr string) string {
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(labels, ", "), expr)
+}
+
+// quantileName renders a quantile as an operation, e.g. 0.99 as "p99".
+func quantileName(q float64) string {
+	percentile := math.Round(q*1e6) / 1e4
+
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(percentile, 'f', -1, 64), ".", "")
+}
+
+// rateRules records the rate of a counter for every window.
+func rateRules(counter string, labels []string, o Opts) []Rule {
+	rules := make([]Rule, 0, len(o.windows()))
+	for _, w := range o.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(by(labels...), counterBase(counter), "rate"+window(w)),
+			Expr:   sumBy(by(labels...), fmt.Sprintf("rate(%s[%s])", counter, window(w))),
+		})
+	}
+
+	return rules
+}
+
+// ratioRules records errors / requests for every window, aggregated by the
+// labels the two counters share.
+func ratioRules(errors string, errorLabels []string, requests string, requestLabels []string, o Opts) []Rule {
+	labels := by(shared(errorLabels, requestLabels)...)
+
+	rules := make([]Rule, 0, len(o.windows()))
+	for _, w := range o.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(labels, fmt.Sprintf("%s_per_%s", counterBase(errors), counterBase(requests)), "ratio_rate"+window(w)),
+			Expr: fmt.Sprintf("%s\n/\n%s",
+				sumBy(labels, fmt.Sprintf("rate(%s[%s])", errors, window(w))),
+				sumBy(labels, fmt.Sprintf("rate(%s[%s])", requests, window(w)))),
+		})
+	}
+
+	return rules
+}
+
+// quantileRules records histogram_quantile over the buckets of a histogram
+// for every window and quantile.
+func quantileRules(histogram string, labels []string, o Opts) []Rule {
+	quantiles := append([]float64(nil), o.quantiles()...)
+	sort.Float64s(quantiles)
+
+	var rules []Rule
+	for _, w := range o.windows() {
+		for _, q := range quantiles {
+			rules = append(rules, Rule{
+				Record: recordName(by(labels...), histogram, quantileName(q)+"_rate"+window(w)),
+				Expr: fmt.Sprintf("histogram_quantile(%s, %s)",
+					strconv.FormatFloat(q, 'f', -1, 64),
+					sumBy(append(by(labels...), "le"), fmt.Sprintf("rate(%s_bucket[%s])", histogram, window(w)))),
+			})
+		}
+	}
+
+	return rules
+}
+
+// averageRules records the average of a gauge for every window.
+func averageRules(gauge string, labels []string, o Opts) []Rule {
+	rules := make([]Rule, 0, len(o.windows()))
+	for _, w := range o.windows() {
+		rules = append(rules, Rule{
+			Record: recordName(by(labels...), gauge, "avg_over_time"+window(w)),
+			Expr:   fmt.Sprintf("avg by (%s) (avg_over_time(%s[%s]))", strings.Join(by(labels...), ", "), gauge, window(w)),
+		})
+	}
+
+	return rules
+}
@@ -0,0 +1,240 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// SLO is a service level objective declared on a RED or FGS strategy, e.g.
+// "99.9% of requests succeed over 30d" or "99% of requests are served in
+// under 300ms over 30d".
+type SLO struct {
+	// Name identifies the SLO. It is attached to every rule as the slo label.
+	Name string `validate:"required"`
+	// Objective is the ratio of good events to aim for, e.g. 0.999.
+	Objective float64 `validate:"gt=0,lt=1"`
+	// Period is the SLO window the error budget is computed over. If not
+	// specified, defaults to 30 days.
+	Period time.Duration
+	// LatencyThreshold, in seconds, turns the SLO into a latency SLO: requests
+	// slower than the threshold are bad. It must be one of the buckets of the
+	// latency histogram. If not specified, the SLO is an availability SLO and
+	// errors are bad.
+	LatencyThreshold float64
+	// RunbookURL is attached to every alert as the runbook_url annotation.
+	RunbookURL string
+	// PageSeverity is the severity label of the fast burn alerts. If not
+	// specified, defaults to "page".
+	PageSeverity string
+	// TicketSeverity is the severity label of the slow burn alerts. If not
+	// specified, defaults to "ticket".
+	TicketSeverity string
+}
+
+// burnRateAlert is one of the multi-window, multi-burn-rate alerts of the SRE
+// workbook: it fires when BudgetConsumed of the error budget was spent within
+// LongWindow, and is still being spent within ShortWindow.
+// https://sre.google/workbook/alerting-on-slos/
+type burnRateAlert struct {
+	LongWindow     time.Duration
+	ShortWindow    time.Duration
+	BudgetConsumed float64
+	Page           bool
+}
+
+var burnRateAlerts = []burnRateAlert{
+	{LongWindow: time.Hour, ShortWindow: 5 * time.Minute, BudgetConsumed: 0.02, Page: true},
+	{LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, BudgetConsumed: 0.05, Page: true},
+	{LongWindow: 24 * time.Hour, ShortWindow: 2 * time.Hour, BudgetConsumed: 0.1, Page: false},
+	{LongWindow: 72 * time.Hour, ShortWindow: 6 * time.Hour, BudgetConsumed: 0.1, Page: false},
+}
+
+// sloErrorRatio is the recording rule metric holding the ratio of bad events.
+const sloErrorRatio = "slo_errors_per_request"
+
+// REDSLOAlerts generates the SLI recording rules and the multi-window,
+// multi-burn-rate alerting rules of an SLO declared on a RED strategy.
+func REDSLOAlerts(red *strategy.RED, slo SLO) (RuleGroup, error) {
+	o := red.Opts()
+
+	return sloAlerts(slo, sli{
+		requests:  fqName(o.Namespace, red.RequestMetricName()),
+		errors:    fqName(o.Namespace, red.ErrorMetricName()),
+		histogram: fqName(o.Namespace, red.Duration.HistogramName()),
+		buckets:   o.DurationOpt.Buckets,
+	})
+}
+
+// FGSSLOAlerts generates the SLI recording rules and the multi-window,
+// multi-burn-rate alerting rules of an SLO declared on a FourGoldenSignals
+// strategy.
+func FGSSLOAlerts(fgs *strategy.FourGoldenSignals, slo SLO) (RuleGroup, error) {
+	o := fgs.Opts()
+
+	return sloAlerts(slo, sli{
+		requests:  fqName(o.Namespace, fgs.TrafficMetricName()),
+		errors:    fqName(o.Namespace, fgs.ErrorMetricName()),
+		histogram: fqName(o.Namespace, fgs.Latency.HistogramName()),
+		buckets:   o.LatencyOpt.Buckets,
+	})
+}
+
+// sli holds the metrics an SLO is computed from.
+type sli struct {
+	requests  string
+	errors    string
+	histogram string
+	buckets   []float64
+}
+
+// errorRatio returns the expression of the ratio of bad events over w.
+func (s sli) errorRatio(slo SLO, w time.Duration) string {
+	if slo.LatencyThreshold == 0 {
+		return fmt.Sprintf("%s\n/\n%s",
+			sumBy([]string{"job"}, fmt.Sprintf("rate(%s[%s])", s.errors, window(w))),
+			sumBy([]string{"job"}, fmt.Sprintf("rate(%s[%s])", s.requests, window(w))))
+	}
+
+	return fmt.Sprintf("1 - (\n%s\n/\n%s\n)",
+		sumBy([]string{"job"}, fmt.Sprintf("rate(%s_bucket{le=%q}[%s])", s.histogram, leValue(slo.LatencyThreshold), window(w))),
+		sumBy([]string{"job"}, fmt.Sprintf("rate(%s_count[%s])", s.histogram, window(w))))
+}
+
+// leValue formats a bucket boundary the way the le label is exposed.
+func leValue(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+func sloAlerts(slo SLO, s sli) (RuleGroup, error) {
+	validate := validator.New()
+	if err := validate.Struct(slo); err != nil {
+		return RuleGroup{}, err
+	}
+
+	if slo.LatencyThreshold != 0 {
+		buckets := s.buckets
+		if buckets == nil {
+			buckets = prometheus.DefBuckets
+		}
+		if !containsFloat(buckets, slo.LatencyThreshold) {
+			return RuleGroup{}, fmt.Errorf("latency threshold %s is not a bucket of %s, buckets are %v",
+				leValue(slo.LatencyThreshold), s.histogram, buckets)
+		}
+	}
+
+	period := slo.Period
+	if period == 0 {
+		period = 30 * 24 * time.Hour
+	}
+
+	var windows []time.Duration
+	for _, a := range burnRateAlerts {
+		for _, w := range []time.Duration{a.ShortWindow, a.LongWindow} {
+			if !containsDuration(windows, w) {
+				windows = append(windows, w)
+			}
+		}
+	}
+
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+
+	rules := make([]Rule, 0, len(windows)+len(burnRateAlerts))
+	for _, w := range windows {
+		rules = append(rules, Rule{
+			Record: recordName([]string{"job"}, sloErrorRatio, "ratio_rate"+window(w)),
+			Expr:   s.errorRatio(slo, w),
+			Labels: map[string]string{"slo": slo.Name},
+		})
+	}
+
+	budget := round(1 - slo.Objective)
+	for _, a := range burnRateAlerts {
+		rules = append(rules, burnRateRule(slo, a, period, budget))
+	}
+
+	return RuleGroup{
+		Name:  "slo:" + slo.Name,
+		Rules: rules,
+	}, nil
+}
+
+func burnRateRule(slo SLO, a burnRateAlert, period time.Duration, budget float64) Rule {
+	burnRate := round(a.BudgetConsumed * float64(period) / float64(a.LongWindow))
+	threshold := fmt.Sprintf("(%s * %s)", formatFloat(burnRate), formatFloat(budget))
+
+	selector := func(w time.Duration) string {
+		return fmt.Sprintf("%s{slo=%q} > %s",
+			recordName([]string{"job"}, sloErrorRatio, "ratio_rate"+window(w)), slo.Name, threshold)
+	}
+
+	severity := slo.TicketSeverity
+	if severity == "" {
+		severity = "ticket"
+	}
+	if a.Page {
+		severity = slo.PageSeverity
+		if severity == "" {
+			severity = "page"
+		}
+	}
+
+	annotations := map[string]string{
+		"summary": fmt.Sprintf("SLO %s is burning its error budget %sx too fast", slo.Name, formatFloat(burnRate)),
+		"description": fmt.Sprintf("%s%% of the %s error budget of SLO %s (objective %s%%) was spent within %s and is still being spent within %s.",
+			formatFloat(a.BudgetConsumed*100), model.Duration(period), slo.Name, formatFloat(round(slo.Objective*100)),
+			model.Duration(a.LongWindow), model.Duration(a.ShortWindow)),
+	}
+	if slo.RunbookURL != "" {
+		annotations["runbook_url"] = slo.RunbookURL
+	}
+
+	return Rule{
+		Alert: "ErrorBudgetBurn",
+		Expr:  strings.Join([]string{selector(a.LongWindow), "and", selector(a.ShortWindow)}, "\n"),
+		Labels: map[string]string{
+			"slo":          slo.Name,
+			"severity":     severity,
+			"long_window":  window(a.LongWindow),
+			"short_window": window(a.ShortWindow),
+		},
+		Annotations: annotations,
+	}
+}
+
+// round drops the floating point noise of ratio arithmetic, e.g. 1 - 0.999.
+func round(f float64) float64 {
+	return math.Round(f*1e9) / 1e9
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func containsFloat(list []float64, f float64) bool {
+	for _, l := range list {
+		if l == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsDuration(list []time.Duration, d time.Duration) bool {
+	for _, l := range list {
+		if l == d {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLOAlertsGolden(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+	fgs := newTestFGS(t)
+
+	tests := map[string]struct {
+		build func() (RuleGroup, error)
+		file  string
+	}{
+		"red availability": {
+			build: func() (RuleGroup, error) {
+				return REDSLOAlerts(red, SLO{
+					Name:       "api-availability",
+					Objective:  0.999,
+					RunbookURL: "https://runbooks.example.com/api-availability",
+				})
+			},
+			file: "testdata/slo_red_availability.yaml",
+		},
+		"fgs latency": {
+			build: func() (RuleGroup, error) {
+				return FGSSLOAlerts(fgs, SLO{
+					Name:             "frontend-latency",
+					Objective:        0.99,
+					Period:           28 * 24 * time.Hour,
+					LatencyThreshold: 0.25,
+					PageSeverity:     "critical",
+					TicketSeverity:   "warning",
+				})
+			},
+			file: "testdata/slo_fgs_latency.yaml",
+		},
+	}
+
+	for name, tt := range tests {
+		build := tt.build
+		file := tt.file
+
+		t.Run(name, func(t *testing.T) {
+			group, err := build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Marshal(group)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, file, got)
+		})
+	}
+}
+
+func TestSLOAlertsBurnRates(t *testing.T) {
+	t.Parallel()
+
+	group, err := REDSLOAlerts(newTestRED(t), SLO{Name: "api", Objective: 0.999})
+	assert.NoError(t, err)
+
+	var alerts []Rule
+	for _, r := range group.Rules {
+		if r.Alert != "" {
+			alerts = append(alerts, r)
+		}
+	}
+
+	// The fast and slow burn thresholds of the SRE workbook for a 30d SLO.
+	assert.Len(t, alerts, 4)
+	assert.Contains(t, alerts[0].Expr, "> (14.4 * 0.001)")
+	assert.Contains(t, alerts[1].Expr, "> (6 * 0.001)")
+	assert.Contains(t, alerts[2].Expr, "> (3 * 0.001)")
+	assert.Contains(t, alerts[3].Expr, "> (1 * 0.001)")
+	assert.Equal(t, "page", alerts[0].Labels["severity"])
+	assert.Equal(t, "ticket", alerts[3].Labels["severity"])
+}
+
+func TestSLOAlertsErrors(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+
+	tests := map[string]struct {
+		slo     SLO
+		wantErr string
+	}{
+		"missing name": {
+			slo:     SLO{Objective: 0.999},
+			wantErr: "'Name' failed on the 'required' tag",
+		},
+		"objective out of range": {
+			slo:     SLO{Name: "api", Objective: 99.9},
+			wantErr: "'Objective' failed on the 'lt' tag",
+		},
+		"threshold not a bucket": {
+			slo:     SLO{Name: "api", Objective: 0.99, LatencyThreshold: 0.3},
+			wantErr: "latency threshold 0.3 is not a bucket of service_http_request_duration_seconds_hist, buckets are [0.05 0.1 0.25 0.5 1]",
+		},
+	}
+
+	for name, tt := range tests {
+		slo := tt.slo
+		wantErr := tt.wantErr
+
+		t.Run(name, func(t *testing.T) {
+			_, err := REDSLOAlerts(red, slo)
+			assert.ErrorContains(t, err, wantErr)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Budget tracks the error budget of an SLO in process, so a service can shed
+// optional work or refuse risky deploys once it is exhausted. Requests and
+// errors are counted in rolling-window time slices: the window is split into
+// Slices buckets and the oldest bucket is dropped as time moves on.
+type Budget struct {
+	// Remaining is the ratio of the error budget left within the window,
+	// {namespace}_slo_error_budget_remaining_ratio. It goes negative once the
+	// budget is overspent.
+	Remaining prometheus.GaugeFunc
+	// BurnRate is how fast the error budget is spent relative to the
+	// objective, {namespace}_slo_burn_rate. A burn rate of 1 spends exactly the
+	// whole budget within the window.
+	BurnRate prometheus.GaugeFunc
+
+	red   strategy.REDRecorder
+	opts  BudgetOpts
+	width time.Duration
+
+	mu     sync.Mutex
+	slices []slice
+}
+
+// slice holds the counts of one time slice of the window.
+type slice struct {
+	// index is the number of slice widths elapsed since the Unix epoch.
+	index  int64
+	total  float64
+	errors float64
+}
+
+// BudgetOpts is the options to create a Budget.
+type BudgetOpts struct {
+	// Name identifies the SLO. It is the value of the slo label.
+	Name string `validate:"required"`
+	// Objective is the ratio of good requests to aim for, e.g. 0.999.
+	Objective float64 `validate:"gt=0,lt=1"`
+	// Window is the rolling period the budget is computed over, e.g. 1 hour.
+	Window time.Duration `validate:"required"`
+	// Slices is the number of buckets the window is split into. More slices
+	// make the window roll more smoothly at the cost of memory. If not
+	// specified, defaults to 60.
+	Slices int `validate:"gte=0"`
+	// Now returns the current time. If not specified, defaults to time.Now.
+	Now func() time.Time
+}
+
+// Status is a point-in-time view of an error budget.
+type Status struct {
+	// Requests is the number of requests within the window.
+	Requests float64
+	// Errors is the number of failed requests within the window.
+	Errors float64
+	// ErrorRatio is Errors / Requests, or 0 without requests.
+	ErrorRatio float64
+	// BurnRate is ErrorRatio / (1 - Objective).
+	BurnRate float64
+	// Remaining is the ratio of the error budget left, 1 - BurnRate.
+	Remaining float64
+}
+
+// Exhausted reports whether the whole error budget was spent.
+func (s Status) Exhausted() bool {
+	return s.Remaining <= 0
+}
+
+// NewBudget creates a Budget fed by the requests and errors of a RED
+// strategy. Record through Recorder for requests and errors to reach both
+// the strategy and the budget.
+func NewBudget(red *strategy.RED, opts BudgetOpts) (*Budget, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Slices == 0 {
+		opts.Slices = 60
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	width := opts.Window / time.Duration(opts.Slices)
+	if width <= 0 {
+		width = 1
+	}
+
+	b := &Budget{
+		red:    red,
+		opts:   opts,
+		width:  width,
+		slices: make([]slice, opts.Slices),
+	}
+
+	namespace := red.Opts().Namespace
+	b.Remaining = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "slo_error_budget_remaining_ratio",
+		Help:        "Ratio of the error budget left within the SLO window",
+		ConstLabels: prometheus.Labels{"slo": opts.Name},
+	}, func() float64 { return b.Status().Remaining })
+	b.BurnRate = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "slo_burn_rate",
+		Help:        "Rate the error budget is spent at relative to the SLO objective",
+		ConstLabels: prometheus.Labels{"slo": opts.Name},
+	}, func() float64 { return b.Status().BurnRate })
+
+	return b, nil
+}
+
+// Register registers the budget gauges with the Prometheus DefaultRegisterer.
+func (b *Budget) Register() error {
+	return metrics.RegisterCollectors(b.Remaining, b.BurnRate)
+}
+
+// Recorder returns a REDRecorder that records with the RED strategy and
+// counts every request, and every error, into the budget.
+func (b *Budget) Recorder() strategy.REDRecorder {
+	return budgetRecorder{budget: b}
+}
+
+// ObserveRequest counts a request into the budget.
+func (b *Budget) ObserveRequest() {
+	b.add(1, 0)
+}
+
+// ObserveError counts a failed request into the budget.
+func (b *Budget) ObserveError() {
+	b.add(0, 1)
+}
+
+func (b *Budget) add(requests, errors float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.current()
+	s.total += requests
+	s.errors += errors
+}
+
+// current returns the slice of the current time, resetting it if it last
+// held an older slice. It must be called with mu held.
+func (b *Budget) current() *slice {
+	index := b.index(b.opts.Now())
+
+	s := &b.slices[modulo(index, int64(len(b.slices)))]
+	if s.index != index {
+		*s = slice{index: index}
+	}
+
+	return s
+}
+
+func (b *Budget) index(t time.Time) int64 {
+	return t.UnixNano() / int64(b.width)
+}
+
+func modulo(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+
+	return m
+}
+
+// Status returns the error budget over the current window.
+func (b *Budget) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	oldest := b.index(b.opts.Now()) - int64(len(b.slices)) + 1
+
+	var s Status
+	for _, sl := range b.slices {
+		if sl.index < oldest {
+			continue
+		}
+		s.Requests += sl.total
+		s.Errors += sl.errors
+	}
+
+	if s.Requests > 0 {
+		s.ErrorRatio = s.Errors / s.Requests
+	}
+	s.BurnRate = s.ErrorRatio / (1 - b.opts.Objective)
+	s.Remaining = 1 - s.BurnRate
+
+	return s
+}
+
+// Exhausted reports whether the whole error budget was spent within the
+// current window.
+func (b *Budget) Exhausted() bool {
+	return b.Status().Exhausted()
+}
+
+// budgetRecorder is the REDRecorder returned by Budget.Recorder.
+type budgetRecorder struct {
+	budget *Budget
+}
+
+func (r budgetRecorder) ObserveRequest(labels ...string) {
+	r.budget.red.ObserveRequest(labels...)
+	r.budget.ObserveRequest()
+}
+
+func (r budgetRecorder) ObserveError(labels ...string) {
+	r.budget.red.ObserveError(labels...)
+	r.budget.ObserveError()
+}
+
+func (r budgetRecorder) ObserveDuration(seconds float64, labels ...string) {
+	r.budget.red.ObserveDuration(seconds, labels...)
+}
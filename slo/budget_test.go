@@ -0,0 +1,182 @@
+package slo
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newTestBudget(t *testing.T, red *strategy.RED, clock *fakeClock) *Budget {
+	t.Helper()
+
+	b, err := NewBudget(red, BudgetOpts{
+		Name:      "api",
+		Objective: 0.99,
+		Window:    time.Hour,
+		Slices:    6,
+		Now:       clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestNewBudgetErrors(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+
+	tests := map[string]BudgetOpts{
+		"missing name": {
+			Objective: 0.99,
+			Window:    time.Hour,
+		},
+		"objective out of range": {
+			Name:      "api",
+			Objective: 0,
+			Window:    time.Hour,
+		},
+		"missing window": {
+			Name:      "api",
+			Objective: 0.99,
+		},
+		"negative slices": {
+			Name:      "api",
+			Objective: 0.99,
+			Window:    time.Hour,
+			Slices:    -1,
+		},
+	}
+
+	for name, opts := range tests {
+		opts := opts
+
+		t.Run(name, func(t *testing.T) {
+			_, err := NewBudget(red, opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBudgetRecorder(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+	b := newTestBudget(t, red, &fakeClock{now: time.Unix(1700000000, 0)})
+
+	s := b.Status()
+	assert.Equal(t, 0.0, s.Requests)
+	assert.Equal(t, 0.0, s.BurnRate)
+	assert.Equal(t, 1.0, s.Remaining)
+	assert.False(t, b.Exhausted())
+
+	rec := b.Recorder()
+	for i := 0; i < 200; i++ {
+		rec.ObserveRequest("/", "GET")
+	}
+	rec.ObserveError("timeout")
+	rec.ObserveDuration(0.2, "/")
+
+	s = b.Status()
+	assert.Equal(t, 200.0, s.Requests)
+	assert.Equal(t, 1.0, s.Errors)
+	assert.InDelta(t, 0.005, s.ErrorRatio, 1e-9)
+	assert.InDelta(t, 0.5, s.BurnRate, 1e-9)
+	assert.InDelta(t, 0.5, s.Remaining, 1e-9)
+	assert.False(t, b.Exhausted())
+
+	assert.InDelta(t, 0.5, testutil.ToFloat64(b.BurnRate), 1e-9)
+	assert.InDelta(t, 0.5, testutil.ToFloat64(b.Remaining), 1e-9)
+
+	assert.Equal(t, 200.0, testutil.ToFloat64(red.Requests.WithLabelValues("/", "GET")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("timeout")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Histogram))
+
+	rec.ObserveError("timeout")
+	rec.ObserveError("timeout")
+	assert.True(t, b.Exhausted())
+	assert.InDelta(t, -0.5, b.Status().Remaining, 1e-9)
+}
+
+func TestBudgetRollingWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	b := newTestBudget(t, newTestRED(t), clock)
+
+	// 10 minute slices: errors in the first slice, requests spread over the
+	// window.
+	b.ObserveRequest()
+	b.ObserveError()
+	for i := 0; i < 5; i++ {
+		clock.Advance(10 * time.Minute)
+		b.ObserveRequest()
+	}
+
+	s := b.Status()
+	assert.Equal(t, 6.0, s.Requests)
+	assert.Equal(t, 1.0, s.Errors)
+
+	// The first slice falls out of the window.
+	clock.Advance(10 * time.Minute)
+	s = b.Status()
+	assert.Equal(t, 5.0, s.Requests)
+	assert.Equal(t, 0.0, s.Errors)
+	assert.Equal(t, 1.0, s.Remaining)
+
+	// Recording into a reused slice starts it from zero.
+	b.ObserveRequest()
+	assert.Equal(t, 6.0, b.Status().Requests)
+
+	// Everything expires after a whole idle window.
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, Status{Remaining: 1}, b.Status())
+}
+
+func TestBudgetConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	b := newTestBudget(t, newTestRED(t), &fakeClock{now: time.Unix(1700000000, 0)})
+	rec := b.Recorder()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rec.ObserveRequest("/", "GET")
+				_ = b.Status()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1000.0, b.Status().Requests)
+}
@@ -0,0 +1,285 @@
+// Package slo records the events of a RED or FGS strategy, classifies them
+// as good or bad against a service level objective, and counts them into
+// dedicated SLI counters that are the simplest inputs for burn-rate math.
+package slo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Event is a single request classified by an SLO.
+type Event struct {
+	// Duration is how long the request took.
+	Duration time.Duration
+	// StatusCode is the status of the request, e.g. an HTTP status code. Zero
+	// when the request has no status.
+	StatusCode int
+	// Err is the error the request failed with, if any.
+	Err error
+	// Labels are the label values the event is recorded with in the wrapped
+	// strategy.
+	Labels Labels
+}
+
+// Labels are the label values of an event, one set per metric of the wrapped
+// strategy.
+type Labels struct {
+	// Requests are the labels of the requests, or traffic, metric.
+	Requests []string
+	// Duration are the labels of the duration, or latency, metric.
+	Duration []string
+	// Errors are the labels of the errors metric, used when the event failed
+	// with an error or a 5xx status.
+	Errors []string
+}
+
+// Predicate reports whether an event is good.
+type Predicate func(Event) bool
+
+// LatencyBelow classifies events served within threshold as good.
+func LatencyBelow(threshold time.Duration) Predicate {
+	return func(e Event) bool {
+		return e.Duration <= threshold
+	}
+}
+
+// StatusNot5xx classifies events that did not fail with an error or a 5xx
+// status as good.
+func StatusNot5xx() Predicate {
+	return func(e Event) bool {
+		return e.Err == nil && e.StatusCode < 500
+	}
+}
+
+// All classifies events as good when every predicate does.
+func All(predicates ...Predicate) Predicate {
+	return func(e Event) bool {
+		for _, p := range predicates {
+			if !p(e) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// SLO wraps a RED or FGS strategy with an objective, a window and a predicate
+// classifying its events. Every observed event is recorded with the strategy,
+// counted in Events and, when good, in GoodEvents, both labelled by the SLO
+// name.
+type SLO struct {
+	// Events is the total number of events, {namespace}_slo_events_total.
+	Events *prometheus.CounterVec
+	// GoodEvents is the number of good events, {namespace}_slo_good_events_total.
+	GoodEvents *prometheus.CounterVec
+	// ObjectiveRatio is the objective, {namespace}_slo_objective_ratio.
+	ObjectiveRatio *prometheus.GaugeVec
+	// WindowSeconds is the window, {namespace}_slo_window_seconds.
+	WindowSeconds *prometheus.GaugeVec
+
+	record func(Event)
+	opts   Opts
+}
+
+// Opts is the options to create an SLO.
+type Opts struct {
+	// Name identifies the SLO. It is the value of the slo label.
+	Name string `validate:"required"`
+	// Objective is the ratio of good events to aim for, e.g. 0.999.
+	Objective float64 `validate:"gt=0,lt=1"`
+	// Window is the period the objective is measured over, e.g. 30 days.
+	Window time.Duration `validate:"required"`
+	// Good classifies an event as good or bad.
+	Good Predicate `validate:"required"`
+}
+
+// ForRED creates an SLO on the events of a RED strategy. Observed events are
+// recorded as requests, durations and, when failed, errors of the strategy.
+// The SLI counters share the namespace of the strategy.
+func ForRED(red *strategy.RED, opts Opts) (*SLO, error) {
+	return newSLO(red.Opts().Namespace, opts, func(e Event) {
+		red.ObserveRequest(e.Labels.Requests...)
+		red.ObserveDuration(e.Duration.Seconds(), e.Labels.Duration...)
+		if failed(e) {
+			red.ObserveError(e.Labels.Errors...)
+		}
+	})
+}
+
+// ForFGS creates an SLO on the events of a FourGoldenSignals strategy.
+// Observed events are recorded as traffic, latencies and, when failed, errors
+// of the strategy. The SLI counters share the namespace of the strategy.
+func ForFGS(fgs *strategy.FourGoldenSignals, opts Opts) (*SLO, error) {
+	return newSLO(fgs.Opts().Namespace, opts, func(e Event) {
+		fgs.ObserveTraffic(e.Labels.Requests...)
+		fgs.ObserveLatency(e.Duration.Seconds(), e.Labels.Duration...)
+		if failed(e) {
+			fgs.ObserveError(e.Labels.Errors...)
+		}
+	})
+}
+
+// failed reports whether e is an error of the wrapped strategy.
+func failed(e Event) bool {
+	return e.Err != nil || e.StatusCode >= 500
+}
+
+func newSLO(namespace string, opts Opts, record func(Event)) (*SLO, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	events, err := metrics.NewCounterWithLabels(metrics.CounterOpts{
+		Namespace: namespace,
+		Name:      "slo_events_total",
+		Help:      "Number of events classified by an SLO",
+		Labels:    []string{"slo"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	goodEvents, err := metrics.NewCounterWithLabels(metrics.CounterOpts{
+		Namespace: namespace,
+		Name:      "slo_good_events_total",
+		Help:      "Number of events an SLO classified as good",
+		Labels:    []string{"slo"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	objective, err := metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+		Namespace: namespace,
+		Name:      "slo_objective_ratio",
+		Help:      "Ratio of good events an SLO aims for",
+		Labels:    []string{"slo"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+		Namespace: namespace,
+		Name:      "slo_window_seconds",
+		Help:      "Period an SLO objective is measured over in seconds",
+		Labels:    []string{"slo"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SLO{
+		Events:         events,
+		GoodEvents:     goodEvents,
+		ObjectiveRatio: objective,
+		WindowSeconds:  window,
+		record:         record,
+		opts:           opts,
+	}
+	s.setTargets()
+
+	return s, nil
+}
+
+// setTargets sets the objective and window gauges of the SLO.
+func (s *SLO) setTargets() {
+	s.ObjectiveRatio.WithLabelValues(s.opts.Name).Set(s.opts.Objective)
+	s.WindowSeconds.WithLabelValues(s.opts.Name).Set(s.opts.Window.Seconds())
+}
+
+// Register registers the SLI counters and the objective and window gauges
+// with the Prometheus DefaultRegisterer. Every SLO of a namespace shares the
+// same metrics, told apart by the slo label, so metrics already registered by
+// another SLO are reused.
+func (s *SLO) Register() error {
+	events, err := registerOrExisting(s.Events)
+	if err != nil {
+		return err
+	}
+
+	goodEvents, err := registerOrExisting(s.GoodEvents)
+	if err != nil {
+		return err
+	}
+
+	objective, err := registerOrExisting(s.ObjectiveRatio)
+	if err != nil {
+		return err
+	}
+
+	window, err := registerOrExisting(s.WindowSeconds)
+	if err != nil {
+		return err
+	}
+
+	s.Events = events
+	s.GoodEvents = goodEvents
+	s.ObjectiveRatio = objective
+	s.WindowSeconds = window
+	s.setTargets()
+
+	return nil
+}
+
+func registerOrExisting[T prometheus.Collector](c T) (T, error) {
+	err := metrics.RegisterCollectors(c)
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Observe records e with the wrapped strategy, classifies it, counts it and
+// reports whether it was good. When several SLOs cover the same events,
+// Observe them with one and Classify them with the others, so that the
+// strategy records every event once.
+func (s *SLO) Observe(e Event) bool {
+	s.record(e)
+
+	return s.Classify(e)
+}
+
+// Classify classifies e, counts it and reports whether it was good, without
+// recording it with the wrapped strategy.
+func (s *SLO) Classify(e Event) bool {
+	good := s.opts.Good(e)
+
+	s.Events.WithLabelValues(s.opts.Name).Inc()
+	if good {
+		s.GoodEvents.WithLabelValues(s.opts.Name).Inc()
+	}
+
+	return good
+}
+
+// Name returns the name of the SLO.
+func (s *SLO) Name() string {
+	return s.opts.Name
+}
+
+// Objective returns the ratio of good events the SLO aims for.
+func (s *SLO) Objective() float64 {
+	return s.opts.Objective
+}
+
+// Window returns the period the objective is measured over.
+func (s *SLO) Window() time.Duration {
+	return s.opts.Window
+}
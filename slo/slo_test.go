@@ -0,0 +1,270 @@
+package slo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRED(t *testing.T) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "service",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"path", "verb"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: []string{"error"},
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationLabels: []string{"path"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func TestPredicates(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		predicate Predicate
+		event     Event
+		want      bool
+	}{
+		"fast enough": {
+			predicate: LatencyBelow(300 * time.Millisecond),
+			event:     Event{Duration: 300 * time.Millisecond},
+			want:      true,
+		},
+		"too slow": {
+			predicate: LatencyBelow(300 * time.Millisecond),
+			event:     Event{Duration: 301 * time.Millisecond},
+			want:      false,
+		},
+		"2xx": {
+			predicate: StatusNot5xx(),
+			event:     Event{StatusCode: 200},
+			want:      true,
+		},
+		"4xx": {
+			predicate: StatusNot5xx(),
+			event:     Event{StatusCode: 404},
+			want:      true,
+		},
+		"5xx": {
+			predicate: StatusNot5xx(),
+			event:     Event{StatusCode: 503},
+			want:      false,
+		},
+		"error without status": {
+			predicate: StatusNot5xx(),
+			event:     Event{Err: errors.New("boom")},
+			want:      false,
+		},
+		"all good": {
+			predicate: All(StatusNot5xx(), LatencyBelow(time.Second)),
+			event:     Event{StatusCode: 200, Duration: time.Millisecond},
+			want:      true,
+		},
+		"all one bad": {
+			predicate: All(StatusNot5xx(), LatencyBelow(time.Second)),
+			event:     Event{StatusCode: 200, Duration: time.Minute},
+			want:      false,
+		},
+		"custom": {
+			predicate: func(e Event) bool { return e.StatusCode != 429 },
+			event:     Event{StatusCode: 429},
+			want:      false,
+		},
+	}
+
+	for name, tt := range tests {
+		predicate := tt.predicate
+		event := tt.event
+		want := tt.want
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, want, predicate(event))
+		})
+	}
+}
+
+func TestForREDErrors(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+
+	tests := map[string]Opts{
+		"missing name": {
+			Objective: 0.999,
+			Window:    time.Hour,
+			Good:      StatusNot5xx(),
+		},
+		"objective out of range": {
+			Name:      "api",
+			Objective: 1,
+			Window:    time.Hour,
+			Good:      StatusNot5xx(),
+		},
+		"missing window": {
+			Name:      "api",
+			Objective: 0.999,
+			Good:      StatusNot5xx(),
+		},
+		"missing predicate": {
+			Name:      "api",
+			Objective: 0.999,
+			Window:    time.Hour,
+		},
+	}
+
+	for name, opts := range tests {
+		opts := opts
+
+		t.Run(name, func(t *testing.T) {
+			_, err := ForRED(red, opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t)
+	s, err := ForRED(red, Opts{
+		Name:      "api-availability",
+		Objective: 0.999,
+		Window:    30 * 24 * time.Hour,
+		Good:      StatusNot5xx(),
+	})
+	assert.NoError(t, err)
+
+	labels := Labels{
+		Requests: []string{"/users", "GET"},
+		Duration: []string{"/users"},
+		Errors:   []string{"internal"},
+	}
+	assert.True(t, s.Observe(Event{StatusCode: 200, Labels: labels}))
+	assert.True(t, s.Observe(Event{StatusCode: 404, Labels: labels}))
+	assert.False(t, s.Observe(Event{StatusCode: 500, Labels: labels}))
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(s.Events.WithLabelValues("api-availability")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(s.GoodEvents.WithLabelValues("api-availability")))
+	assert.Equal(t, "api-availability", s.Name())
+	assert.Equal(t, 0.999, s.Objective())
+	assert.Equal(t, 30*24*time.Hour, s.Window())
+	assert.Equal(t, 0.999, testutil.ToFloat64(s.ObjectiveRatio.WithLabelValues("api-availability")))
+	assert.Equal(t, (30 * 24 * time.Hour).Seconds(), testutil.ToFloat64(s.WindowSeconds.WithLabelValues("api-availability")))
+
+	// The events reach the wrapped strategy too.
+	assert.Equal(t, 3.0, testutil.ToFloat64(red.Requests.WithLabelValues("/users", "GET")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("internal")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Histogram))
+
+	// Classify leaves the strategy alone.
+	assert.False(t, s.Classify(Event{StatusCode: 503}))
+	assert.Equal(t, 4.0, testutil.ToFloat64(s.Events.WithLabelValues("api-availability")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(red.Requests.WithLabelValues("/users", "GET")))
+}
+
+func TestObserveFGS(t *testing.T) {
+	t.Parallel()
+
+	fgs, err := strategy.NewFourGoldenSignals(strategy.FourGoldenSignalsOpts{
+		Namespace: "service",
+		LatencyOpt: strategy.FGSLatencyOpt{
+			LatencyName:   "http_request_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "Request latency in seconds",
+			LatencyLabels: []string{"path"},
+		},
+		TrafficOpt: strategy.FGSTrafficOpt{
+			TrafficName:   "http_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Number of requests",
+			TrafficLabels: []string{"path"},
+		},
+		ErrorsOpt: strategy.FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"error"},
+		},
+		SaturationOpt: strategy.FGSSaturationOpt{
+			SaturationName:   "queue_depth",
+			SaturationHelp:   "Requests waiting",
+			SaturationLabels: []string{"queue"},
+		},
+	})
+	assert.NoError(t, err)
+
+	s, err := ForFGS(fgs, Opts{
+		Name:      "latency",
+		Objective: 0.99,
+		Window:    time.Hour,
+		Good:      LatencyBelow(300 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+
+	labels := Labels{Requests: []string{"/"}, Duration: []string{"/"}, Errors: []string{"timeout"}}
+	assert.True(t, s.Observe(Event{Duration: 100 * time.Millisecond, Labels: labels}))
+	assert.False(t, s.Observe(Event{Duration: time.Second, Err: errors.New("timeout"), Labels: labels}))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("/")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Errors.WithLabelValues("timeout")))
+	assert.Equal(t, 1, testutil.CollectAndCount(fgs.Latency.Histogram))
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestRegisterSharesCounters(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	red := newTestRED(t)
+
+	availability, err := ForRED(red, Opts{
+		Name:      "availability",
+		Objective: 0.999,
+		Window:    time.Hour,
+		Good:      StatusNot5xx(),
+	})
+	assert.NoError(t, err)
+
+	latency, err := ForRED(red, Opts{
+		Name:      "latency",
+		Objective: 0.99,
+		Window:    time.Hour,
+		Good:      LatencyBelow(300 * time.Millisecond),
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, availability.Register())
+	assert.NoError(t, latency.Register())
+
+	// Both SLOs cover the same event, recorded with the strategy once.
+	event := Event{
+		Duration:   time.Second,
+		StatusCode: 200,
+		Labels:     Labels{Requests: []string{"/", "GET"}, Duration: []string{"/"}},
+	}
+	availability.Observe(event)
+	latency.Classify(event)
+
+	assert.Same(t, availability.Events, latency.Events)
+	assert.Equal(t, 2, testutil.CollectAndCount(registry, "service_slo_events_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "service_slo_good_events_total"))
+	assert.Equal(t, 2, testutil.CollectAndCount(registry, "service_slo_objective_ratio"))
+	assert.Equal(t, 2, testutil.CollectAndCount(registry, "service_slo_window_seconds"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("/", "GET")))
+}
@@ -0,0 +1,103 @@
+package sqlmetrics
+
+import (
+	"database/sql"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// StatsGetter is implemented by *sql.DB.
+type StatsGetter interface {
+	Stats() sql.DBStats
+}
+
+var _ StatsGetter = (*sql.DB)(nil)
+
+// DBStatsCollector collects the sql.DBStats of a connection pool with USE
+// semantics, labelled by pool name:
+//
+//	{namespace}_sql_pool_utilization_ratio             in-use connections over max open connections
+//	{namespace}_sql_pool_saturation_waits_total        connections waited for
+//	{namespace}_sql_pool_saturation_wait_seconds_total time spent waiting for connections
+//	{namespace}_sql_pool_errors_total                  connections closed, by reason
+//
+// When the pool has no max open connections, utilization is in-use over
+// open connections. The closing reasons are "max_idle", "max_idle_time" and
+// "max_lifetime". Several collectors with different pool names can be
+// registered with the same registry, and a DBStatsCollector can be a field
+// of a Strategy struct.
+type DBStatsCollector struct {
+	db StatsGetter
+
+	utilization *prometheus.Desc
+	waits       *prometheus.Desc
+	waitSeconds *prometheus.Desc
+	errors      *prometheus.Desc
+}
+
+// DBStatsOpts is the options to create a DBStatsCollector.
+type DBStatsOpts struct {
+	Namespace string `validate:"required"`
+	// Pool names the connection pool. It is the value of the pool label.
+	Pool string `validate:"required"`
+}
+
+// NewDBStatsCollector creates a DBStatsCollector of db, usually a *sql.DB.
+func NewDBStatsCollector(db StatsGetter, opts DBStatsOpts) (*DBStatsCollector, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	labels := prometheus.Labels{"pool": opts.Pool}
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, "sql_pool", name), help, variableLabels, labels)
+	}
+
+	return &DBStatsCollector{
+		db:          db,
+		utilization: desc("utilization_ratio", "Ratio of in-use connections to max open connections"),
+		waits:       desc("saturation_waits_total", "Number of connections waited for"),
+		waitSeconds: desc("saturation_wait_seconds_total", "Time spent waiting for connections in seconds"),
+		errors:      desc("errors_total", "Number of connections closed by the pool limits", "reason"),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *DBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.utilization
+	ch <- c.waits
+	ch <- c.waitSeconds
+	ch <- c.errors
+}
+
+// Collect implements prometheus.Collector.
+func (c *DBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.utilization, prometheus.GaugeValue, utilization(stats))
+	ch <- prometheus.MustNewConstMetric(c.waits, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stats.MaxIdleClosed), "max_idle")
+	ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), "max_idle_time")
+	ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), "max_lifetime")
+}
+
+func utilization(stats sql.DBStats) float64 {
+	capacity := stats.MaxOpenConnections
+	if capacity == 0 {
+		capacity = stats.OpenConnections
+	}
+	if capacity == 0 {
+		return 0
+	}
+
+	return float64(stats.InUse) / float64(capacity)
+}
+
+// Register registers the collector with the Prometheus DefaultRegisterer.
+func (c *DBStatsCollector) Register() error {
+	return metrics.RegisterCollectors(c)
+}
@@ -0,0 +1,116 @@
+package sqlmetrics
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStats sql.DBStats
+
+func (s fakeStats) Stats() sql.DBStats {
+	return sql.DBStats(s)
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	t.Parallel()
+
+	primary, err := NewDBStatsCollector(fakeStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    6,
+		InUse:              4,
+		WaitCount:          3,
+		WaitDuration:       1500 * time.Millisecond,
+		MaxIdleClosed:      1,
+		MaxIdleTimeClosed:  2,
+		MaxLifetimeClosed:  5,
+	}, DBStatsOpts{Namespace: "service", Pool: "primary"})
+	assert.NoError(t, err)
+
+	// Without max open connections, utilization is over open connections.
+	replica, err := NewDBStatsCollector(fakeStats{
+		OpenConnections: 4,
+		InUse:           1,
+	}, DBStatsOpts{Namespace: "service", Pool: "replica"})
+	assert.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(primary))
+	assert.NoError(t, registry.Register(replica))
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP service_sql_pool_errors_total Number of connections closed by the pool limits
+# TYPE service_sql_pool_errors_total counter
+service_sql_pool_errors_total{pool="primary",reason="max_idle"} 1
+service_sql_pool_errors_total{pool="primary",reason="max_idle_time"} 2
+service_sql_pool_errors_total{pool="primary",reason="max_lifetime"} 5
+service_sql_pool_errors_total{pool="replica",reason="max_idle"} 0
+service_sql_pool_errors_total{pool="replica",reason="max_idle_time"} 0
+service_sql_pool_errors_total{pool="replica",reason="max_lifetime"} 0
+# HELP service_sql_pool_saturation_wait_seconds_total Time spent waiting for connections in seconds
+# TYPE service_sql_pool_saturation_wait_seconds_total counter
+service_sql_pool_saturation_wait_seconds_total{pool="primary"} 1.5
+service_sql_pool_saturation_wait_seconds_total{pool="replica"} 0
+# HELP service_sql_pool_saturation_waits_total Number of connections waited for
+# TYPE service_sql_pool_saturation_waits_total counter
+service_sql_pool_saturation_waits_total{pool="primary"} 3
+service_sql_pool_saturation_waits_total{pool="replica"} 0
+# HELP service_sql_pool_utilization_ratio Ratio of in-use connections to max open connections
+# TYPE service_sql_pool_utilization_ratio gauge
+service_sql_pool_utilization_ratio{pool="primary"} 0.4
+service_sql_pool_utilization_ratio{pool="replica"} 0.25
+`))
+	assert.NoError(t, err)
+}
+
+func TestDBStatsCollectorEmptyPool(t *testing.T) {
+	t.Parallel()
+
+	c, err := NewDBStatsCollector(fakeStats{}, DBStatsOpts{Namespace: "service", Pool: "primary"})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, testutil.CollectAndCount(c))
+	assert.Equal(t, 0.0, utilization(sql.DBStats{}))
+}
+
+func TestNewDBStatsCollectorErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewDBStatsCollector(fakeStats{}, DBStatsOpts{Namespace: "service"})
+	assert.Error(t, err)
+
+	_, err = NewDBStatsCollector(fakeStats{}, DBStatsOpts{Pool: "primary"})
+	assert.Error(t, err)
+}
+
+// database is a Strategy with a DBStatsCollector field.
+type database struct {
+	Calls *strategy.RED
+	Pool  *DBStatsCollector
+}
+
+func (d database) Register() error {
+	return strategy.RegisterStrategyFields(d)
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestDBStatsCollectorStrategyField(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	pool, err := NewDBStatsCollector(fakeStats{MaxOpenConnections: 2, InUse: 1}, DBStatsOpts{Namespace: "service", Pool: "primary"})
+	assert.NoError(t, err)
+
+	d := database{Calls: newTestRED(t, "error"), Pool: pool}
+	assert.NoError(t, d.Register())
+
+	assert.Equal(t, 6, testutil.CollectAndCount(registry, "service_sql_pool_utilization_ratio",
+		"service_sql_pool_saturation_waits_total", "service_sql_pool_saturation_wait_seconds_total",
+		"service_sql_pool_errors_total"))
+}
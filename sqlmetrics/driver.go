@@ -0,0 +1,451 @@
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Wrap returns a driver recording the calls made through d with a RED
+// strategy. Register it with sql.Register under a new name and open
+// databases with that name. It fails if a label of the strategy is not
+// supported.
+func Wrap(d driver.Driver, red *strategy.RED, opts Opts) (driver.Driver, error) {
+	rec, err := newRecorder(red, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedDriver{parent: d, rec: rec}, nil
+}
+
+// WrapConnector returns a connector recording the calls made through c with
+// a RED strategy, to open databases with sql.OpenDB. It fails if a label of
+// the strategy is not supported.
+func WrapConnector(c driver.Connector, red *strategy.RED, opts Opts) (driver.Connector, error) {
+	rec, err := newRecorder(red, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConnector{
+		parent: c,
+		driver: &wrappedDriver{parent: c.Driver(), rec: rec},
+		rec:    rec,
+	}, nil
+}
+
+var (
+	_ driver.Driver        = (*wrappedDriver)(nil)
+	_ driver.DriverContext = (*wrappedDriver)(nil)
+	_ driver.Connector     = (*wrappedConnector)(nil)
+
+	_ driver.Conn               = (*wrappedConn)(nil)
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.Pinger             = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+
+	_ driver.Stmt              = (*wrappedStmt)(nil)
+	_ driver.StmtExecContext   = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext  = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker = (*wrappedStmt)(nil)
+	_ driver.ColumnConverter   = (*wrappedStmt)(nil)
+
+	_ driver.Tx = (*wrappedTx)(nil)
+)
+
+type wrappedDriver struct {
+	parent driver.Driver
+	rec    *recorder
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{parent: conn, rec: d.rec}, nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.parent.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &wrappedConnector{parent: c, driver: d, rec: d.rec}, nil
+	}
+
+	return &wrappedConnector{parent: dsnConnector{name: name, driver: d.parent}, driver: d, rec: d.rec}, nil
+}
+
+// dsnConnector is the connector of drivers that do not implement
+// driver.DriverContext.
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type wrappedConnector struct {
+	parent driver.Connector
+	driver driver.Driver
+	rec    *recorder
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.parent.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedConn{parent: conn, rec: c.rec}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// wrappedConn implements every optional interface of driver.Conn, falling
+// back to what database/sql does when the parent connection does not.
+type wrappedConn struct {
+	parent driver.Conn
+	rec    *recorder
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	name := queryName(ctx)
+
+	stmt, err := c.prepareContext(ctx, query)
+	c.rec.record(name, OpPrepare, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedStmt{parent: stmt, conn: c, query: name}, nil
+}
+
+func (c *wrappedConn) prepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.parent.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+
+	stmt, err := c.parent.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		stmt.Close()
+
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+func (c *wrappedConn) Close() error {
+	return c.parent.Close()
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	name := queryName(ctx)
+
+	tx, err := c.beginTx(ctx, opts)
+	c.rec.record(name, OpBegin, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wrappedTx{parent: tx, rec: c.rec, query: name}, nil
+}
+
+func (c *wrappedConn) beginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.parent.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != 0 {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	//nolint:staticcheck // the fallback of drivers without ConnBeginTx.
+	return c.parent.Begin()
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	res, err := c.execContext(ctx, query, args)
+	c.rec.record(queryName(ctx), OpExec, start, err)
+
+	return res, err
+}
+
+func (c *wrappedConn) execContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.parent.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+
+	//nolint:staticcheck // the fallback of drivers without ExecerContext.
+	if e, ok := c.parent.(driver.Execer); ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return e.Exec(query, values)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	rows, err := c.queryContext(ctx, query, args)
+	c.rec.record(queryName(ctx), OpQuery, start, err)
+
+	return rows, err
+}
+
+func (c *wrappedConn) queryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.parent.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+
+	//nolint:staticcheck // the fallback of drivers without QueryerContext.
+	if q, ok := c.parent.(driver.Queryer); ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return q.Query(query, values)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.parent.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.parent.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.parent.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.parent.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// wrappedStmt implements every optional interface of driver.Stmt, falling
+// back to what database/sql does when the parent statement does not.
+type wrappedStmt struct {
+	parent driver.Stmt
+	conn   *wrappedConn
+	// query is the name of the query the statement was prepared with.
+	query string
+}
+
+func (s *wrappedStmt) Close() error {
+	return s.parent.Close()
+}
+
+func (s *wrappedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+
+	res, err := s.parent.Exec(args)
+	s.conn.rec.record(s.query, OpExec, start, err)
+
+	return res, err
+}
+
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+
+	rows, err := s.parent.Query(args)
+	s.conn.rec.record(s.query, OpQuery, start, err)
+
+	return rows, err
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	res, err := s.execContext(ctx, args)
+	s.conn.rec.record(s.name(ctx), OpExec, start, err)
+
+	return res, err
+}
+
+func (s *wrappedStmt) execContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.parent.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	//nolint:staticcheck // the fallback of drivers without StmtExecContext.
+	return s.parent.Exec(values)
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	rows, err := s.queryContext(ctx, args)
+	s.conn.rec.record(s.name(ctx), OpQuery, start, err)
+
+	return rows, err
+}
+
+func (s *wrappedStmt) queryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := s.parent.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	//nolint:staticcheck // the fallback of drivers without StmtQueryContext.
+	return s.parent.Query(values)
+}
+
+// name returns the query name of ctx, or the name the statement was prepared
+// with when ctx has none.
+func (s *wrappedStmt) name(ctx context.Context) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return name
+	}
+
+	return s.query
+}
+
+// CheckNamedValue delegates to the statement, then to the connection, as
+// database/sql does; driver.ErrSkip makes database/sql use ColumnConverter.
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.parent.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+
+	return s.conn.CheckNamedValue(nv)
+}
+
+func (s *wrappedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	//nolint:staticcheck // ColumnConverter is still honored by database/sql.
+	if c, ok := s.parent.(driver.ColumnConverter); ok {
+		return c.ColumnConverter(idx)
+	}
+
+	return driver.DefaultParameterConverter
+}
+
+type wrappedTx struct {
+	parent driver.Tx
+	rec    *recorder
+	// query is the name of the query the transaction began with.
+	query string
+}
+
+func (t *wrappedTx) Commit() error {
+	start := time.Now()
+
+	err := t.parent.Commit()
+	t.rec.record(t.query, OpCommit, start, err)
+
+	return err
+}
+
+func (t *wrappedTx) Rollback() error {
+	start := time.Now()
+
+	err := t.parent.Rollback()
+	t.rec.record(t.query, OpRollback, start, err)
+
+	return err
+}
+
+func namedValuesToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = nv.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,211 @@
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRED(t *testing.T, errorLabels ...string) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "db",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestName:   "calls_total",
+			RequestType:   "sql",
+			RequestLabels: []string{"operation", "query"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: errorLabels,
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationName:   "call_duration_seconds",
+			DurationLabels: []string{"op", "outcome"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func openTestDB(t *testing.T, d driver.Driver, red *strategy.RED, opts Opts) *sql.DB {
+	t.Helper()
+
+	wrapped, err := Wrap(d, red, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connector, err := wrapped.(driver.DriverContext).OpenConnector("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "operation", "query", "error_class")
+	db := openTestDB(t, fakeDriver{}, red, Opts{})
+	ctx := WithQueryName(context.Background(), "get-user")
+
+	var n int
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+	_, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "jane")
+	assert.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "fail UPDATE")
+	assert.ErrorIs(t, err, errSyntax)
+
+	stmt, err := db.PrepareContext(WithQueryName(context.Background(), "insert-user"), "INSERT")
+	assert.NoError(t, err)
+	_, err = stmt.ExecContext(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, stmt.Close())
+
+	tx, err := db.BeginTx(WithQueryName(context.Background(), "transfer"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	tx, err = db.BeginTx(WithQueryName(context.Background(), "transfer"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+
+	_, err = db.Exec("SELECT 1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpQuery, "get-user")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "get-user")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpPrepare, "insert-user")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "insert-user")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpBegin, "transfer")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpCommit, "transfer")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpRollback, "transfer")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "unknown")))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Errors))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues(OpExec, "get-user", "other")))
+}
+
+func TestWrapErrorClasses(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "error")
+	db := openTestDB(t, fakeDriver{}, red, Opts{})
+
+	// database/sql retries twice on a cached or new connection, then once on
+	// a new one.
+	_, err := db.Exec("badconn")
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, 3.0, testutil.ToFloat64(red.Errors.WithLabelValues("bad_conn")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "wait")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("timeout")))
+}
+
+func TestWrapClassifier(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "class")
+	db := openTestDB(t, fakeDriver{}, red, Opts{
+		Classify: func(err error) string {
+			if errors.Is(err, errSyntax) {
+				return "syntax"
+			}
+			return DefaultClassifier(err)
+		},
+	})
+
+	_, err := db.Exec("fail")
+	assert.Error(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("syntax")))
+}
+
+func TestWrapLegacyDriver(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "error")
+	db := openTestDB(t, fakeDriver{legacy: true}, red, Opts{})
+	ctx := WithQueryName(context.Background(), "count")
+
+	// Without ExecerContext and QueryerContext, database/sql prepares the
+	// statement first; the skipped direct call is not recorded.
+	var n int
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+	_, err := db.ExecContext(ctx, "DELETE", 1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpPrepare, "count")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpQuery, "count")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "count")))
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.Error(t, err)
+	assert.Nil(t, tx)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("other")))
+}
+
+// registrations numbers the drivers registered by tests, since database/sql
+// panics on a name registered twice, e.g. with -count=2.
+var registrations int64
+
+func TestWrapRegister(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "error")
+	wrapped, err := Wrap(fakeDriver{}, red, Opts{})
+	assert.NoError(t, err)
+
+	name := fmt.Sprintf("sqlmetrics-fake-%d", atomic.AddInt64(&registrations, 1))
+	sql.Register(name, wrapped)
+	db, err := sql.Open(name, "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, db.Ping())
+	_, err = db.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "unknown")))
+}
+
+func TestWrapConnector(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "error")
+	connector, err := WrapConnector(dsnConnector{driver: fakeDriver{}}, red, Opts{})
+	assert.NoError(t, err)
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	_, err = db.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues(OpExec, "unknown")))
+}
+
+func TestWrapUnsupportedLabel(t *testing.T) {
+	t.Parallel()
+
+	_, err := Wrap(fakeDriver{}, newTestRED(t, "sql"), Opts{})
+	assert.Error(t, err)
+}
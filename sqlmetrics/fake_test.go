@@ -0,0 +1,124 @@
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+)
+
+// fakeDriver is an in-memory driver whose behaviour is driven by the query:
+// queries starting with "badconn" fail with driver.ErrBadConn, with "wait"
+// block until their context is done and with "fail" fail with a syntax
+// error. Every other query succeeds, returning a single row.
+//
+// A legacy fakeDriver only implements driver.Conn and driver.Stmt, so that
+// database/sql falls back to preparing statements.
+type fakeDriver struct {
+	legacy bool
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	if d.legacy {
+		return &legacyConn{}, nil
+	}
+
+	return &fakeConn{}, nil
+}
+
+var errSyntax = errors.New("syntax error")
+
+func run(ctx context.Context, query string) error {
+	switch {
+	case strings.HasPrefix(query, "badconn"):
+		return driver.ErrBadConn
+	case strings.HasPrefix(query, "wait"):
+		<-ctx.Done()
+
+		return ctx.Err()
+	case strings.HasPrefix(query, "fail"):
+		return errSyntax
+	default:
+		return nil
+	}
+}
+
+type legacyConn struct{}
+
+func (c *legacyConn) Prepare(query string) (driver.Stmt, error) {
+	if err := run(context.Background(), query); err != nil {
+		return nil, err
+	}
+
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *legacyConn) Close() error { return nil }
+
+func (c *legacyConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeConn struct {
+	legacyConn
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := run(ctx, query); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := run(ctx, query); err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package sqlmetrics instruments database/sql with strategies.
+//
+// The driver wrapper records every Exec, Query, Prepare, Begin, Commit and
+// Rollback with a RED strategy. The label values of every metric are derived
+// from the label names the strategy was created with:
+//
+//	operation, op              "exec", "query", "prepare", "begin", "commit" or "rollback"
+//	query                      the query name, see WithQueryName; never the raw SQL
+//	outcome                    "error" when the call failed, "success" otherwise
+//	error, error_class, class  the class of the error, see Opts.Classify, or "" on success
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// The operations recorded by the driver wrapper.
+const (
+	OpExec     = "exec"
+	OpQuery    = "query"
+	OpPrepare  = "prepare"
+	OpBegin    = "begin"
+	OpCommit   = "commit"
+	OpRollback = "rollback"
+)
+
+// Opts is the options to wrap a driver.
+type Opts struct {
+	// Classify returns the class of the errors calls fail with. Classes are
+	// label values, so it must return a small, bounded set of them. If not
+	// specified, defaults to DefaultClassifier.
+	Classify func(err error) string
+}
+
+// DefaultClassifier classifies driver.ErrBadConn as "bad_conn",
+// context.DeadlineExceeded as "timeout", context.Canceled as "canceled" and
+// every other error as "other".
+func DefaultClassifier(err error) string {
+	switch {
+	case errors.Is(err, driver.ErrBadConn):
+		return "bad_conn"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "other"
+	}
+}
+
+type queryNameKey struct{}
+
+// WithQueryName returns a copy of ctx naming the queries made with it, e.g.
+// "get-user". Queries without a name are labelled "unknown". Commit and
+// Rollback are labelled with the name of the context the transaction began
+// with.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+func queryName(ctx context.Context) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// call holds what label values are derived from.
+type call struct {
+	operation string
+	query     string
+	failed    bool
+	class     string
+}
+
+var labelValues = map[string]func(call) string{
+	"operation":   func(c call) string { return c.operation },
+	"op":          func(c call) string { return c.operation },
+	"query":       func(c call) string { return c.query },
+	"outcome":     outcome,
+	"error":       func(c call) string { return c.class },
+	"error_class": func(c call) string { return c.class },
+	"class":       func(c call) string { return c.class },
+}
+
+func outcome(c call) string {
+	if c.failed {
+		return "error"
+	}
+
+	return "success"
+}
+
+// recorder records calls with a RED strategy.
+type recorder struct {
+	red            *strategy.RED
+	classify       func(err error) string
+	requestLabels  labels.Labeler[call]
+	errorLabels    labels.Labeler[call]
+	durationLabels labels.Labeler[call]
+}
+
+func newRecorder(red *strategy.RED, opts Opts) (*recorder, error) {
+	o := red.Opts()
+
+	requestLabels, err := labels.New(labelValues, o.RequestsOpt.RequestLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	durationLabels, err := labels.New(labelValues, o.DurationOpt.DurationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	classify := opts.Classify
+	if classify == nil {
+		classify = DefaultClassifier
+	}
+
+	return &recorder{
+		red:            red,
+		classify:       classify,
+		requestLabels:  requestLabels,
+		errorLabels:    errorLabels,
+		durationLabels: durationLabels,
+	}, nil
+}
+
+// record records a call of operation op started at start that returned err.
+// driver.ErrSkip is not recorded: it only tells database/sql to fall back to
+// another way of making the call, which is recorded in turn.
+func (r *recorder) record(query, op string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	c := call{operation: op, query: query}
+	if err != nil {
+		c.failed = true
+		c.class = r.classify(err)
+	}
+
+	r.red.ObserveRequest(r.requestLabels.Values(c)...)
+	if c.failed {
+		r.red.ObserveError(r.errorLabels.Values(c)...)
+	}
+	r.red.ObserveDuration(time.Since(start).Seconds(), r.durationLabels.Values(c)...)
+}
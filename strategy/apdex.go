@@ -0,0 +1,243 @@
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// Apdex describes the metrics to report an Apdex score: the ratio of
+// satisfied requests plus half the tolerating ones to all requests. Requests
+// served within the target threshold T are satisfied, those slower than T
+// but within 4T (T < d <= 4T) tolerating and slower ones frustrated.
+// https://en.wikipedia.org/wiki/Apdex
+type Apdex struct {
+	// Distributions of the amount of time each request takes. T and 4T are
+	// always bucket boundaries of the histogram.
+	Duration *Distribution
+	// The number of requests served within T.
+	Satisfied *prometheus.CounterVec
+	// The number of requests slower than T but served within 4T, T < d <= 4T.
+	Tolerating *prometheus.CounterVec
+	// The number of requests served slower than 4T.
+	Frustrated *prometheus.CounterVec
+	// The Apdex score since the process started, between 0 and 1.
+	Score *prometheus.GaugeVec
+
+	opts   ApdexOpts
+	scores *apdexScores
+}
+
+// ApdexOpts is the options to create an Apdex strategy.
+type ApdexOpts struct {
+	Namespace string `validate:"required"`
+	// Name is the prefix of the Apdex counters and score metrics. If not
+	// specified, defaults to "apdex".
+	Name string
+	// DurationName is the name of the duration metric. If not specified,
+	// defaults to "request_duration_seconds".
+	DurationName string
+	// Target is the threshold T, in seconds, requests are satisfied within.
+	Target float64 `validate:"gt=0"`
+	// Labels are the labels to attach to every metric.
+	Labels []string `validate:"required"`
+	// Buckets defines the histogram buckets into which observations are
+	// counted. T and 4T are added to them. If not specified, defaults to the
+	// Prometheus default buckets.
+	Buckets []float64
+	// Objectives defines the summary quantile rank estimates with their respective
+	// absolute error.
+	Objectives map[float64]float64
+}
+
+// apdexScores holds the per label set counts behind the Score gauge.
+type apdexScores struct {
+	mu     sync.Mutex
+	counts map[string]*apdexCounts
+}
+
+type apdexCounts struct {
+	satisfied, tolerating, total float64
+}
+
+// NewApdex creates an Apdex strategy.
+func NewApdex(opts ApdexOpts) (*Apdex, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	duration, err := NewDistribution(DistributionOpts{
+		Namespace:  opts.Namespace,
+		Name:       getApdexDurationMetricName(opts),
+		Help:       "Duration of request in seconds",
+		Labels:     opts.Labels,
+		Buckets:    apdexBuckets(opts),
+		Objectives: opts.Objectives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counter := func(zone, help string) (*prometheus.CounterVec, error) {
+		return metrics.NewCounterWithLabels(metrics.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s_total", getApdexMetricPrefix(opts), zone),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	satisfied, err := counter("satisfied", "Number of requests served within the Apdex target")
+	if err != nil {
+		return nil, err
+	}
+
+	tolerating, err := counter("tolerating", "Number of requests slower than the Apdex target but served within 4 times it")
+	if err != nil {
+		return nil, err
+	}
+
+	frustrated, err := counter("frustrated", "Number of requests served slower than 4 times the Apdex target")
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+		Namespace: opts.Namespace,
+		Name:      getApdexScoreMetricName(opts),
+		Help:      "Apdex score since the process started",
+		Labels:    opts.Labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Apdex{
+		Duration:   duration,
+		Satisfied:  satisfied,
+		Tolerating: tolerating,
+		Frustrated: frustrated,
+		Score:      score,
+		opts:       opts,
+		scores:     &apdexScores{counts: map[string]*apdexCounts{}},
+	}, nil
+}
+
+// apdexBuckets returns the histogram buckets with T and 4T added.
+func apdexBuckets(opts ApdexOpts) []float64 {
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+
+	out := append([]float64(nil), buckets...)
+	for _, b := range []float64{opts.Target, 4 * opts.Target} {
+		found := false
+		for _, o := range out {
+			if o == b {
+				found = true
+
+				break
+			}
+		}
+		if !found {
+			out = append(out, b)
+		}
+	}
+
+	sort.Float64s(out)
+
+	return out
+}
+
+// Register registers the Apdex strategy with the Prometheus DefaultRegisterer.
+func (a Apdex) Register() error {
+	err := RegisterStrategyFields(a)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Observe records how long a request took in seconds, counts it as
+// satisfied, tolerating or frustrated and updates the score.
+func (a Apdex) Observe(seconds float64, labels ...string) {
+	a.Duration.Observe(seconds, labels...)
+
+	var satisfied, tolerating float64
+	switch {
+	case seconds <= a.opts.Target:
+		satisfied = 1
+		a.Satisfied.WithLabelValues(labels...).Inc()
+	case seconds <= 4*a.opts.Target:
+		tolerating = 1
+		a.Tolerating.WithLabelValues(labels...).Inc()
+	default:
+		a.Frustrated.WithLabelValues(labels...).Inc()
+	}
+
+	a.scores.mu.Lock()
+	defer a.scores.mu.Unlock()
+
+	key := strings.Join(labels, "\xff")
+	c, ok := a.scores.counts[key]
+	if !ok {
+		c = &apdexCounts{}
+		a.scores.counts[key] = c
+	}
+	c.satisfied += satisfied
+	c.tolerating += tolerating
+	c.total++
+
+	a.Score.WithLabelValues(labels...).Set((c.satisfied + c.tolerating/2) / c.total)
+}
+
+// Opts returns the options the Apdex strategy was created with.
+func (a Apdex) Opts() ApdexOpts {
+	return a.opts
+}
+
+func (a Apdex) SatisfiedMetricName() string {
+	return fmt.Sprintf("%s_satisfied_total", getApdexMetricPrefix(a.opts))
+}
+
+func (a Apdex) ToleratingMetricName() string {
+	return fmt.Sprintf("%s_tolerating_total", getApdexMetricPrefix(a.opts))
+}
+
+func (a Apdex) FrustratedMetricName() string {
+	return fmt.Sprintf("%s_frustrated_total", getApdexMetricPrefix(a.opts))
+}
+
+func (a Apdex) ScoreMetricName() string {
+	return getApdexScoreMetricName(a.opts)
+}
+
+func (a Apdex) DurationMetricName() string {
+	return getApdexDurationMetricName(a.opts)
+}
+
+func getApdexMetricPrefix(opts ApdexOpts) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+	return "apdex"
+}
+
+func getApdexScoreMetricName(opts ApdexOpts) string {
+	return fmt.Sprintf("%s_score", getApdexMetricPrefix(opts))
+}
+
+func getApdexDurationMetricName(opts ApdexOpts) string {
+	if opts.DurationName != "" {
+		return opts.DurationName
+	}
+	return "request_duration_seconds"
+}
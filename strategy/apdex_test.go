@@ -0,0 +1,151 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewApdex(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts        ApdexOpts
+		wantBuckets []float64
+		wantErr     bool
+	}{
+		"adds T and 4T to the buckets": {
+			opts: ApdexOpts{
+				Namespace: "foobar",
+				Target:    0.3,
+				Labels:    []string{"path"},
+				Buckets:   []float64{0.1, 0.5, 2},
+			},
+			wantBuckets: []float64{0.1, 0.3, 0.5, 1.2, 2},
+		},
+		"keeps existing boundaries": {
+			opts: ApdexOpts{
+				Namespace: "foobar",
+				Target:    0.5,
+				Labels:    []string{"path"},
+				Buckets:   []float64{0.5, 1, 2},
+			},
+			wantBuckets: []float64{0.5, 1, 2},
+		},
+		"default buckets": {
+			opts: ApdexOpts{
+				Namespace: "foobar",
+				Target:    0.3,
+				Labels:    []string{"path"},
+			},
+			wantBuckets: []float64{.005, .01, .025, .05, .1, .25, .3, .5, 1, 1.2, 2.5, 5, 10},
+		},
+		"missing target": {
+			opts: ApdexOpts{
+				Namespace: "foobar",
+				Labels:    []string{"path"},
+			},
+			wantErr: true,
+		},
+		"missing labels": {
+			opts: ApdexOpts{
+				Namespace: "foobar",
+				Target:    0.3,
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		opts := tt.opts
+		wantBuckets := tt.wantBuckets
+		wantErr := tt.wantErr
+
+		t.Run(name, func(t *testing.T) {
+			got, err := NewApdex(opts)
+			if (err != nil) != wantErr {
+				t.Errorf("NewApdex error = %v, wantErr %v", err, wantErr)
+
+				return
+			}
+			if wantErr {
+				return
+			}
+			assert.Equal(t, wantBuckets, got.Duration.Opts().Buckets)
+		})
+	}
+}
+
+func TestApdexObserve(t *testing.T) {
+	t.Parallel()
+
+	apdex, err := NewApdex(ApdexOpts{
+		Namespace: "test",
+		Target:    0.5,
+		Labels:    []string{"path"},
+	})
+	assert.NoError(t, err)
+
+	apdex.Observe(0.1, "/happy")
+	apdex.Observe(0.5, "/happy")
+	apdex.Observe(1.5, "/happy")
+	apdex.Observe(2.5, "/happy")
+	apdex.Observe(0.1, "/sad")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(apdex.Satisfied.WithLabelValues("/happy")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apdex.Tolerating.WithLabelValues("/happy")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apdex.Frustrated.WithLabelValues("/happy")))
+	assert.Equal(t, 0.625, testutil.ToFloat64(apdex.Score.WithLabelValues("/happy")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(apdex.Score.WithLabelValues("/sad")))
+	assert.Equal(t, 2, testutil.CollectAndCount(apdex.Duration.Histogram))
+}
+
+func TestApdexMetricNames(t *testing.T) {
+	t.Parallel()
+
+	apdex, err := NewApdex(ApdexOpts{
+		Namespace: "test",
+		Target:    0.5,
+		Labels:    []string{"path"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "apdex_satisfied_total", apdex.SatisfiedMetricName())
+	assert.Equal(t, "apdex_tolerating_total", apdex.ToleratingMetricName())
+	assert.Equal(t, "apdex_frustrated_total", apdex.FrustratedMetricName())
+	assert.Equal(t, "apdex_score", apdex.ScoreMetricName())
+	assert.Equal(t, "request_duration_seconds", apdex.DurationMetricName())
+
+	named, err := NewApdex(ApdexOpts{
+		Namespace:    "test",
+		Name:         "checkout_apdex",
+		DurationName: "checkout_duration_seconds",
+		Target:       0.5,
+		Labels:       []string{"path"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "checkout_apdex_satisfied_total", named.SatisfiedMetricName())
+	assert.Equal(t, "checkout_apdex_score", named.ScoreMetricName())
+	assert.Equal(t, "checkout_duration_seconds_hist", named.Duration.HistogramName())
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestApdexRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	apdex, err := NewApdex(ApdexOpts{
+		Namespace: "test",
+		Target:    0.5,
+		Labels:    []string{"path"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, apdex.Register())
+
+	apdex.Observe(0.1, "/happy")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "test_apdex_score"))
+}
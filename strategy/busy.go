@@ -0,0 +1,276 @@
+package strategy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// BusyTracker turns intervals of work into the utilization of a USE strategy:
+// the average time the resource was busy, per label set of the utilization
+// metric. Work is tracked with Begin and End, or with Busy.
+//
+// Overlapping intervals are counted once per unit of capacity: a resource
+// of capacity 1 is busy while at least one interval is open, and a resource
+// of capacity 4, e.g. 4 workers, is fully busy while 4 or more are.
+//
+// The BusyTracker is a prometheus.Collector computing utilization at scrape
+// time, over the last Window or since the previous scrape, and exposing it
+// as the utilization metric of the strategy. Register it next to the
+// strategy, and don't set the utilization of the label sets it tracks.
+type BusyTracker struct {
+	opts BusyOpts
+	desc *prometheus.Desc
+
+	mu          sync.Mutex
+	series      map[string]*busySeries
+	lastScraped time.Time
+}
+
+// BusyOpts is the options to create a BusyTracker.
+type BusyOpts struct {
+	// Capacity is the number of intervals the resource serves concurrently.
+	// If not specified, defaults to 1.
+	Capacity int `validate:"gte=0"`
+	// Window is the time utilization is computed over. If not specified,
+	// utilization covers the time since the previous scrape, which is only
+	// meaningful with a single scraper.
+	Window time.Duration `validate:"gte=0"`
+	// Slices is the number of slices Window is divided into. Busy time
+	// leaves the window one slice at a time. If not specified, defaults to
+	// 60.
+	Slices int `validate:"gte=0"`
+	// Now returns the current time. If not specified, defaults to time.Now.
+	Now func() time.Time
+}
+
+// busySeries is the busy time of a label set.
+type busySeries struct {
+	labels []string
+	// open is the number of open intervals.
+	open int
+	// since is when the series was first seen.
+	since time.Time
+	// last is when busy time was last accumulated.
+	last time.Time
+	// busy is the busy time accumulated since the series was first seen,
+	// in seconds of capacity.
+	busy float64
+	// scraped is busy at the previous scrape.
+	scraped float64
+	// slices holds the busy time of the last slices of Window, indexed by
+	// slice number modulo the number of slices.
+	slices []busySlice
+}
+
+type busySlice struct {
+	number int64
+	busy   float64
+}
+
+// NewBusyTracker creates a BusyTracker of the utilization of use.
+func NewBusyTracker(use *USE, opts BusyOpts) (*BusyTracker, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Capacity == 0 {
+		opts.Capacity = 1
+	}
+	if opts.Slices == 0 {
+		opts.Slices = 60
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Window > 0 && opts.Window < time.Duration(opts.Slices) {
+		return nil, errors.New("window is shorter than a nanosecond per slice")
+	}
+
+	o := use.Opts()
+
+	return &BusyTracker{
+		opts: opts,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(o.Namespace, "", o.UtilizationOpt.UtilizationName),
+			o.UtilizationOpt.UtilizationHelp,
+			o.UtilizationOpt.UtilizationLabels,
+			nil,
+		),
+		series:      map[string]*busySeries{},
+		lastScraped: opts.Now(),
+	}, nil
+}
+
+// BusyInterval is an interval of work opened with Begin.
+type BusyInterval struct {
+	tracker *BusyTracker
+	series  *busySeries
+	once    sync.Once
+}
+
+// Begin opens an interval of work of the label set labels. The interval
+// lasts until End is called.
+func (b *BusyTracker) Begin(labels ...string) *BusyInterval {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.get(labels)
+	b.advance(s, b.opts.Now())
+	s.open++
+
+	return &BusyInterval{tracker: b, series: s}
+}
+
+// End closes the interval. Calling it again does nothing.
+func (i *BusyInterval) End() {
+	i.once.Do(func() {
+		b := i.tracker
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		b.advance(i.series, b.opts.Now())
+		i.series.open--
+	})
+}
+
+// Busy runs fn as an interval of work of the label set labels.
+func (b *BusyTracker) Busy(fn func(), labels ...string) {
+	i := b.Begin(labels...)
+	defer i.End()
+
+	fn()
+}
+
+// Describe implements prometheus.Collector. It describes nothing: the
+// utilization metric is described by the strategy.
+func (b *BusyTracker) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (b *BusyTracker) Collect(ch chan<- prometheus.Metric) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.opts.Now()
+	elapsed := now.Sub(b.lastScraped).Seconds()
+	b.lastScraped = now
+
+	for _, s := range b.series {
+		b.advance(s, now)
+
+		var utilization float64
+		if b.opts.Window > 0 {
+			utilization = b.windowed(s, now)
+		} else if elapsed > 0 {
+			utilization = (s.busy - s.scraped) / elapsed
+		}
+		s.scraped = s.busy
+
+		ch <- prometheus.MustNewConstMetric(b.desc, prometheus.GaugeValue, utilization, s.labels...)
+	}
+}
+
+// Register registers the BusyTracker with the Prometheus DefaultRegisterer.
+func (b *BusyTracker) Register() error {
+	return metrics.RegisterCollectors(b)
+}
+
+// get must be called with mu held.
+func (b *BusyTracker) get(labels []string) *busySeries {
+	key := strings.Join(labels, "\xff")
+
+	s, ok := b.series[key]
+	if !ok {
+		now := b.opts.Now()
+		s = &busySeries{
+			labels: append([]string(nil), labels...),
+			since:  now,
+			last:   now,
+			slices: make([]busySlice, b.opts.Slices),
+		}
+		b.series[key] = s
+	}
+
+	return s
+}
+
+// advance accumulates the busy time of s until now. It must be called with
+// mu held.
+func (b *BusyTracker) advance(s *busySeries, now time.Time) {
+	if !now.After(s.last) {
+		return
+	}
+
+	level := float64(s.open)
+	if level > float64(b.opts.Capacity) {
+		level = float64(b.opts.Capacity)
+	}
+	level /= float64(b.opts.Capacity)
+
+	s.busy += level * now.Sub(s.last).Seconds()
+
+	if b.opts.Window > 0 && level > 0 {
+		width := b.width()
+
+		// Busy time older than the window is dropped anyway.
+		start := s.last
+		if oldest := now.Add(-b.opts.Window - width); start.Before(oldest) {
+			start = oldest
+		}
+
+		for start.Before(now) {
+			number := start.UnixNano() / int64(width)
+			end := time.Unix(0, (number+1)*int64(width))
+			if end.After(now) {
+				end = now
+			}
+
+			slice := &s.slices[number%int64(len(s.slices))]
+			if slice.number != number {
+				*slice = busySlice{number: number}
+			}
+			slice.busy += level * end.Sub(start).Seconds()
+
+			start = end
+		}
+	}
+
+	s.last = now
+}
+
+// windowed returns the utilization of s over the window ending at now. It
+// must be called with mu held.
+func (b *BusyTracker) windowed(s *busySeries, now time.Time) float64 {
+	width := b.width()
+	current := now.UnixNano() / int64(width)
+
+	var busy float64
+	for _, slice := range s.slices {
+		if slice.number > current-int64(len(s.slices)) && slice.number <= current {
+			busy += slice.busy
+		}
+	}
+
+	// The window covers the previous slices and the current one so far, or
+	// less for series younger than that.
+	covered := time.Duration(len(s.slices)-1)*width + now.Sub(time.Unix(0, current*int64(width)))
+	if age := now.Sub(s.since); age < covered {
+		covered = age
+	}
+	if covered <= 0 {
+		return 0
+	}
+
+	return busy / covered.Seconds()
+}
+
+func (b *BusyTracker) width() time.Duration {
+	return b.opts.Window / time.Duration(b.opts.Slices)
+}
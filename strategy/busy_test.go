@@ -0,0 +1,219 @@
+package strategy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock tests advance by hand.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newBusyTestUSE(t *testing.T) *USE {
+	t.Helper()
+
+	use, err := NewUSE(USEOpts{
+		Namespace: "worker",
+		UtilizationOpt: USEUtilizationOpt{
+			UtilizationName:   "pool_utilization_ratio",
+			UtilizationHelp:   "Busy time over wall time",
+			UtilizationLabels: []string{"pool"},
+		},
+		SaturationOpt: USESaturationOpt{
+			SaturationName:   "pool_saturation_pending_tasks",
+			SaturationHelp:   "Tasks waiting for a worker",
+			SaturationLabels: []string{"pool"},
+		},
+		ErrorsOpt: USEErrorsOpt{
+			ErrorLabels: []string{"pool"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return use
+}
+
+// utilizations collects b and returns the utilization of every pool.
+func utilizations(t *testing.T, b *BusyTracker) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(b); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			values[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+
+	return values
+}
+
+func TestNewBusyTracker(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts BusyOpts
+		err  string
+	}{
+		"defaults":        {opts: BusyOpts{}},
+		"window":          {opts: BusyOpts{Window: time.Minute, Slices: 6}},
+		"negative window": {opts: BusyOpts{Window: -time.Second}, err: "BusyOpts.Window"},
+		"tiny window":     {opts: BusyOpts{Window: 10, Slices: 60}, err: "window is shorter"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewBusyTracker(newBusyTestUSE(t), tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBusyTrackerSinceScrape(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	b, err := NewBusyTracker(newBusyTestUSE(t), BusyOpts{Now: clock.Now})
+	assert.NoError(t, err)
+
+	// Busy for 3s of 10s, with overlapping intervals counted once.
+	first := b.Begin("emails")
+	clock.Advance(time.Second)
+	second := b.Begin("emails")
+	clock.Advance(2 * time.Second)
+	first.End()
+	first.End()
+	second.End()
+	clock.Advance(7 * time.Second)
+
+	assert.InDelta(t, 0.3, utilizations(t, b)["emails"], 1e-9)
+
+	// Still busy at the scrape: the open interval counts until then.
+	b.Begin("emails")
+	clock.Advance(5 * time.Second)
+
+	assert.InDelta(t, 1.0, utilizations(t, b)["emails"], 1e-9)
+}
+
+func TestBusyTrackerCapacity(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	b, err := NewBusyTracker(newBusyTestUSE(t), BusyOpts{Capacity: 4, Now: clock.Now})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		b.Begin("emails")
+	}
+	for i := 0; i < 6; i++ {
+		b.Begin("reports")
+	}
+	clock.Advance(10 * time.Second)
+
+	values := utilizations(t, b)
+	assert.InDelta(t, 0.5, values["emails"], 1e-9)
+	assert.InDelta(t, 1.0, values["reports"], 1e-9)
+}
+
+func TestBusyTrackerWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	b, err := NewBusyTracker(newBusyTestUSE(t), BusyOpts{Window: time.Minute, Slices: 6, Now: clock.Now})
+	assert.NoError(t, err)
+
+	b.Busy(func() { clock.Advance(30 * time.Second) }, "emails")
+	clock.Advance(30 * time.Second)
+
+	// The window spans the last 5 slices and the current one so far, 50s at
+	// a slice boundary: the busy time of the first slice has left it.
+	assert.InDelta(t, 20.0/50, utilizations(t, b)["emails"], 1e-9)
+	// Unlike since the previous scrape, a second scrape sees the same window.
+	assert.InDelta(t, 20.0/50, utilizations(t, b)["emails"], 1e-9)
+
+	clock.Advance(15 * time.Second)
+	assert.InDelta(t, 10.0/55, utilizations(t, b)["emails"], 1e-9)
+
+	clock.Advance(time.Hour)
+	assert.InDelta(t, 0.0, utilizations(t, b)["emails"], 1e-9)
+}
+
+func TestBusyTrackerWindowYoungSeries(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	b, err := NewBusyTracker(newBusyTestUSE(t), BusyOpts{Window: time.Hour, Now: clock.Now})
+	assert.NoError(t, err)
+
+	b.Begin("emails")
+	clock.Advance(time.Minute)
+
+	// A minute old series busy all along is fully utilized, not at 1/60.
+	assert.InDelta(t, 1.0, utilizations(t, b)["emails"], 1e-9)
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestBusyTrackerRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = reg
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	use := newBusyTestUSE(t)
+	b, err := NewBusyTracker(use, BusyOpts{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, use.Register())
+	assert.NoError(t, b.Register())
+
+	b.Busy(func() {}, "emails")
+	use.SetSaturation(1, "emails")
+
+	count, err := testutil.GatherAndCount(reg, "worker_pool_utilization_ratio")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,251 @@
+package strategy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// The results of a cache lookup, the values of the result label of the
+// lookups metric.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Cache describes the metrics of a cache: lookups by result, from which the
+// hit ratio is derived, evictions by reason, how full the cache is and how
+// long loading missing entries takes.
+type Cache struct {
+	// The number of lookups, by result: hit, miss or error.
+	Lookups *prometheus.CounterVec
+	// The number of entries evicted, by reason, e.g. "expired" or "capacity".
+	Evictions *prometheus.CounterVec
+	// The number of entries, or bytes, held by the cache.
+	Size *prometheus.GaugeVec
+	// The number of entries, or bytes, the cache can hold.
+	Capacity *prometheus.GaugeVec
+	// The ratio of the size to the capacity of the cache.
+	Utilization *prometheus.GaugeVec
+	// Distributions of the amount of time loading a missing entry takes.
+	LoadDuration *Distribution
+
+	opts  CacheOpts
+	sizes *cacheSizes
+}
+
+// CacheOpts is the options to create a Cache strategy.
+type CacheOpts struct {
+	Namespace string `validate:"required"`
+	// Name is the prefix of the cache metrics. If not specified, defaults to
+	// "cache".
+	Name string
+	// Labels are the labels to attach to every metric, e.g. "cache". The
+	// lookups metric adds a "result" label and the evictions metric a
+	// "reason" label.
+	Labels []string `validate:"required"`
+	// Buckets defines the buckets of the load duration histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	Buckets []float64
+	// Objectives defines the summary quantile rank estimates with their
+	// respective absolute error.
+	Objectives map[float64]float64
+}
+
+// cacheSizes holds the per label set size and capacity behind the
+// Utilization gauge.
+type cacheSizes struct {
+	mu     sync.Mutex
+	values map[string]*cacheSize
+}
+
+type cacheSize struct {
+	size, capacity float64
+}
+
+// NewCache creates a Cache strategy.
+func NewCache(opts CacheOpts) (*Cache, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	lookups, err := metrics.NewCounterWithLabels(metrics.CounterOpts{
+		Namespace: opts.Namespace,
+		Name:      getCacheLookupsMetricName(opts),
+		Help:      "Number of cache lookups by result",
+		Labels:    appendLabel(opts.Labels, "result"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	evictions, err := metrics.NewCounterWithLabels(metrics.CounterOpts{
+		Namespace: opts.Namespace,
+		Name:      fmt.Sprintf("%s_evictions_total", getCacheMetricPrefix(opts)),
+		Help:      "Number of cache entries evicted by reason",
+		Labels:    appendLabel(opts.Labels, "reason"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gauge := func(name, help string) (*prometheus.GaugeVec, error) {
+		return metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s", getCacheMetricPrefix(opts), name),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	size, err := gauge("size", "Number of entries, or bytes, held by the cache")
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, err := gauge("capacity", "Number of entries, or bytes, the cache can hold")
+	if err != nil {
+		return nil, err
+	}
+
+	utilization, err := gauge("utilization_ratio", "Ratio of the size to the capacity of the cache")
+	if err != nil {
+		return nil, err
+	}
+
+	loadDuration, err := NewDistribution(DistributionOpts{
+		Namespace:  opts.Namespace,
+		Name:       getCacheLoadDurationMetricName(opts),
+		Help:       "Duration of loading missing cache entries in seconds",
+		Labels:     opts.Labels,
+		Buckets:    opts.Buckets,
+		Objectives: opts.Objectives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		Lookups:      lookups,
+		Evictions:    evictions,
+		Size:         size,
+		Capacity:     capacity,
+		Utilization:  utilization,
+		LoadDuration: loadDuration,
+		opts:         opts,
+		sizes:        &cacheSizes{values: map[string]*cacheSize{}},
+	}, nil
+}
+
+// appendLabel returns a copy of labels with label appended.
+func appendLabel(labels []string, label string) []string {
+	return append(append([]string(nil), labels...), label)
+}
+
+// Register registers the Cache strategy with the Prometheus DefaultRegisterer.
+func (c Cache) Register() error {
+	err := RegisterStrategyFields(c)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ObserveLookup records a lookup with its result, one of CacheHit,
+// CacheMiss and CacheError.
+func (c Cache) ObserveLookup(result string, labels ...string) {
+	c.Lookups.WithLabelValues(appendLabel(labels, result)...).Inc()
+}
+
+// ObserveEviction records that an entry was evicted for reason. Reasons are
+// label values, so they must be a small, bounded set.
+func (c Cache) ObserveEviction(reason string, labels ...string) {
+	c.Evictions.WithLabelValues(appendLabel(labels, reason)...).Inc()
+}
+
+// ObserveLoad records how long loading a missing entry took in seconds.
+func (c Cache) ObserveLoad(seconds float64, labels ...string) {
+	c.LoadDuration.Observe(seconds, labels...)
+}
+
+// SetSize records the size of the cache and updates its utilization.
+func (c Cache) SetSize(size float64, labels ...string) {
+	c.Size.WithLabelValues(labels...).Set(size)
+	c.setUtilization(func(s *cacheSize) { s.size = size }, labels)
+}
+
+// SetCapacity records the capacity of the cache and updates its
+// utilization. Caches without a capacity have no utilization.
+func (c Cache) SetCapacity(capacity float64, labels ...string) {
+	c.Capacity.WithLabelValues(labels...).Set(capacity)
+	c.setUtilization(func(s *cacheSize) { s.capacity = capacity }, labels)
+}
+
+func (c Cache) setUtilization(update func(s *cacheSize), labels []string) {
+	c.sizes.mu.Lock()
+	defer c.sizes.mu.Unlock()
+
+	key := strings.Join(labels, "\xff")
+	s, ok := c.sizes.values[key]
+	if !ok {
+		s = &cacheSize{}
+		c.sizes.values[key] = s
+	}
+	update(s)
+
+	if s.capacity > 0 {
+		c.Utilization.WithLabelValues(labels...).Set(s.size / s.capacity)
+	}
+}
+
+// Opts returns the options the Cache strategy was created with.
+func (c Cache) Opts() CacheOpts {
+	return c.opts
+}
+
+func (c Cache) LookupsMetricName() string {
+	return getCacheLookupsMetricName(c.opts)
+}
+
+func (c Cache) EvictionsMetricName() string {
+	return fmt.Sprintf("%s_evictions_total", getCacheMetricPrefix(c.opts))
+}
+
+func (c Cache) SizeMetricName() string {
+	return fmt.Sprintf("%s_size", getCacheMetricPrefix(c.opts))
+}
+
+func (c Cache) CapacityMetricName() string {
+	return fmt.Sprintf("%s_capacity", getCacheMetricPrefix(c.opts))
+}
+
+func (c Cache) UtilizationMetricName() string {
+	return fmt.Sprintf("%s_utilization_ratio", getCacheMetricPrefix(c.opts))
+}
+
+func (c Cache) LoadDurationMetricName() string {
+	return getCacheLoadDurationMetricName(c.opts)
+}
+
+func getCacheMetricPrefix(opts CacheOpts) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+
+	return "cache"
+}
+
+func getCacheLookupsMetricName(opts CacheOpts) string {
+	return fmt.Sprintf("%s_lookups_total", getCacheMetricPrefix(opts))
+}
+
+func getCacheLoadDurationMetricName(opts CacheOpts) string {
+	return fmt.Sprintf("%s_load_duration_seconds", getCacheMetricPrefix(opts))
+}
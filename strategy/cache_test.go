@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCache(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts CacheOpts
+		err  string
+	}{
+		"all good": {
+			opts: CacheOpts{Namespace: "service", Labels: []string{"cache"}},
+		},
+		"missing namespace": {
+			opts: CacheOpts{Labels: []string{"cache"}},
+			err:  "CacheOpts.Namespace",
+		},
+		"missing labels": {
+			opts: CacheOpts{Namespace: "service"},
+			err:  "CacheOpts.Labels",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewCache(tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCacheMetricNames(t *testing.T) {
+	t.Parallel()
+
+	cache, err := NewCache(CacheOpts{Namespace: "service", Labels: []string{"cache"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "cache_lookups_total", cache.LookupsMetricName())
+	assert.Equal(t, "cache_evictions_total", cache.EvictionsMetricName())
+	assert.Equal(t, "cache_size", cache.SizeMetricName())
+	assert.Equal(t, "cache_capacity", cache.CapacityMetricName())
+	assert.Equal(t, "cache_utilization_ratio", cache.UtilizationMetricName())
+	assert.Equal(t, "cache_load_duration_seconds", cache.LoadDurationMetricName())
+
+	named, err := NewCache(CacheOpts{Namespace: "service", Name: "sessions", Labels: []string{"cache"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "sessions_lookups_total", named.LookupsMetricName())
+	assert.Equal(t, "sessions_load_duration_seconds_hist", named.LoadDuration.HistogramName())
+}
+
+func TestCacheObserve(t *testing.T) {
+	t.Parallel()
+
+	cache, err := NewCache(CacheOpts{Namespace: "service", Labels: []string{"cache"}})
+	assert.NoError(t, err)
+
+	cache.ObserveLookup(CacheHit, "users")
+	cache.ObserveLookup(CacheHit, "users")
+	cache.ObserveLookup(CacheMiss, "users")
+	cache.ObserveEviction("expired", "users")
+	cache.ObserveLoad(0.25, "users")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(cache.Lookups.WithLabelValues("users", CacheHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(cache.Lookups.WithLabelValues("users", CacheMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(cache.Evictions.WithLabelValues("users", "expired")))
+	assert.Equal(t, 1, testutil.CollectAndCount(cache.LoadDuration.Histogram))
+}
+
+func TestCacheUtilization(t *testing.T) {
+	t.Parallel()
+
+	cache, err := NewCache(CacheOpts{Namespace: "service", Labels: []string{"cache"}})
+	assert.NoError(t, err)
+
+	// Without a capacity there is no utilization.
+	cache.SetSize(10, "users")
+	assert.Equal(t, 0, testutil.CollectAndCount(cache.Utilization))
+
+	cache.SetCapacity(40, "users")
+	assert.Equal(t, 0.25, testutil.ToFloat64(cache.Utilization.WithLabelValues("users")))
+
+	cache.SetSize(30, "users")
+	cache.SetCapacity(100, "sessions")
+	assert.Equal(t, 0.75, testutil.ToFloat64(cache.Utilization.WithLabelValues("users")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(cache.Utilization.WithLabelValues("sessions")))
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestCacheRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	cache, err := NewCache(CacheOpts{Namespace: "service", Labels: []string{"cache"}})
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Register())
+
+	cache.ObserveLookup(CacheHit, "users")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "service_cache_lookups_total"))
+}
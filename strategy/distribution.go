@@ -1,6 +1,7 @@
 package strategy
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/go-playground/validator"
@@ -65,6 +66,7 @@ func NewDistribution(opts DistributionOpts) (*Distribution, error) {
 	return &Distribution{
 		Histogram: histogram,
 		Summary:   summary,
+		opts:      opts,
 	}, nil
 }
 
@@ -79,6 +81,58 @@ func (r Distribution) Register() error {
 	return nil
 }
 
+// RegisterShared registers the Distribution with the Prometheus
+// DefaultRegisterer like Register, unless a Distribution with the same names
+// and labels is registered already, in which case it returns that one. It
+// lets the instances of a helper, told apart by a label, share a single
+// Distribution: the buckets and objectives of the first one registered win.
+func (r Distribution) RegisterShared() (*Distribution, error) {
+	histogram, err := registerOrExisting(r.Histogram)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := registerOrExisting(r.Summary)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := r
+	shared.Histogram = histogram
+	shared.Summary = summary
+
+	return &shared, nil
+}
+
+func registerOrExisting[T prometheus.Collector](c T) (T, error) {
+	err := metrics.RegisterCollectors(c)
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		var zero T
+
+		return zero, err
+	}
+
+	return c, nil
+}
+
+// Observe adds a single observation to both the histogram and the summary.
+func (r Distribution) Observe(value float64, labels ...string) {
+	r.Histogram.WithLabelValues(labels...).Observe(value)
+	r.Summary.WithLabelValues(labels...).Observe(value)
+}
+
+// Opts returns the options the Distribution was created with.
+func (r Distribution) Opts() DistributionOpts {
+	return r.opts
+}
+
 func (r Distribution) HistogramName() string {
 	return getDistributionHistogramName(r.opts)
 }
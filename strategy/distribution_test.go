@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -60,3 +61,53 @@ func TestNewDistribution(t *testing.T) {
 		})
 	}
 }
+
+func TestDistributionNames(t *testing.T) {
+	t.Parallel()
+
+	distribution, err := NewDistribution(DistributionOpts{
+		Namespace: "foobar",
+		Name:      "foo",
+		Help:      "bar",
+		Labels:    []string{"baz"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "foo_hist", distribution.HistogramName())
+	assert.Equal(t, "foo_sum", distribution.SummaryName())
+	assert.Equal(t, "foo", distribution.Opts().Name)
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestDistributionRegisterShared(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	opts := DistributionOpts{Namespace: "jobs", Name: "wait_seconds", Help: "Wait", Labels: []string{"pool"}}
+
+	first, err := NewDistribution(opts)
+	assert.NoError(t, err)
+	second, err := NewDistribution(opts)
+	assert.NoError(t, err)
+
+	firstShared, err := first.RegisterShared()
+	assert.NoError(t, err)
+	secondShared, err := second.RegisterShared()
+	assert.NoError(t, err)
+	assert.Same(t, firstShared.Histogram, secondShared.Histogram)
+	assert.Same(t, firstShared.Summary, secondShared.Summary)
+
+	firstShared.Observe(1, "emails")
+	secondShared.Observe(1, "reports")
+
+	count, err := testutil.GatherAndCount(registry, "jobs_wait_seconds_hist")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// A different metric of the same name still fails.
+	conflicting, err := NewDistribution(DistributionOpts{Namespace: "jobs", Name: "wait_seconds", Help: "Wait", Labels: []string{"queue"}})
+	assert.NoError(t, err)
+	_, err = conflicting.RegisterShared()
+	assert.Error(t, err)
+}
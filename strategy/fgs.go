@@ -169,6 +169,32 @@ func (f FourGoldenSignals) Register() error {
 	return nil
 }
 
+// ObserveLatency records the latency of a request in seconds with both the
+// histogram and the summary.
+func (f FourGoldenSignals) ObserveLatency(seconds float64, labels ...string) {
+	f.Latency.Observe(seconds, labels...)
+}
+
+// ObserveTraffic increments the traffic counter.
+func (f FourGoldenSignals) ObserveTraffic(labels ...string) {
+	f.Traffic.WithLabelValues(labels...).Inc()
+}
+
+// ObserveError increments the errors counter.
+func (f FourGoldenSignals) ObserveError(labels ...string) {
+	f.Errors.WithLabelValues(labels...).Inc()
+}
+
+// SetSaturation sets the saturation gauge.
+func (f FourGoldenSignals) SetSaturation(value float64, labels ...string) {
+	f.Saturation.WithLabelValues(labels...).Set(value)
+}
+
+// Opts returns the options the FourGoldenSignals strategy was created with.
+func (f FourGoldenSignals) Opts() FourGoldenSignalsOpts {
+	return f.opts
+}
+
 func (f FourGoldenSignals) LatencyMetricName() string {
 	return getFGSLatencyMetricName(f.opts)
 }
@@ -0,0 +1,378 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+// Job describes the metrics of batch and cron jobs: when they last started,
+// succeeded and failed, how long they run, how many items they process and
+// whether they are running. Jobs too short-lived to be scraped report with
+// Pusher or WriteTextfile once they are done.
+type Job struct {
+	// The Unix time the job last started at.
+	LastStart *prometheus.GaugeVec
+	// The Unix time the job last succeeded at.
+	LastSuccess *prometheus.GaugeVec
+	// The Unix time the job last failed at.
+	LastFailure *prometheus.GaugeVec
+	// Distributions of the amount of time each run takes.
+	Duration *Distribution
+	// The number of items processed.
+	Processed *prometheus.CounterVec
+	// The number of items that failed.
+	Failed *prometheus.CounterVec
+	// The number of items skipped.
+	Skipped *prometheus.CounterVec
+	// The number of runs in progress.
+	Running *prometheus.GaugeVec
+
+	opts JobOpts
+}
+
+// JobOpts is the options to create a Job strategy.
+type JobOpts struct {
+	Namespace string `validate:"required"`
+	// Name is the prefix of the job metrics. If not specified, defaults to
+	// "job".
+	Name string
+	// Labels are the labels to attach to every metric, e.g. "task". Avoid
+	// "job", which Prometheus sets to the name of the scrape job.
+	Labels []string `validate:"required"`
+	// Buckets defines the buckets of the duration histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	Buckets []float64
+	// Objectives defines the summary quantile rank estimates with their
+	// respective absolute error.
+	Objectives map[float64]float64
+	// Now returns the current time. If not specified, defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewJob creates a Job strategy.
+func NewJob(opts JobOpts) (*Job, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	gauge := func(name, help string) (*prometheus.GaugeVec, error) {
+		return metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s", getJobMetricPrefix(opts), name),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	counter := func(name, help string) (*prometheus.CounterVec, error) {
+		return metrics.NewCounterWithLabels(metrics.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s", getJobMetricPrefix(opts), name),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	lastStart, err := gauge("last_start_timestamp_seconds", "Unix time the job last started at")
+	if err != nil {
+		return nil, err
+	}
+
+	lastSuccess, err := gauge("last_success_timestamp_seconds", "Unix time the job last succeeded at")
+	if err != nil {
+		return nil, err
+	}
+
+	lastFailure, err := gauge("last_failure_timestamp_seconds", "Unix time the job last failed at")
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := NewDistribution(DistributionOpts{
+		Namespace:  opts.Namespace,
+		Name:       getJobDurationMetricName(opts),
+		Help:       "Duration of job runs in seconds",
+		Labels:     opts.Labels,
+		Buckets:    opts.Buckets,
+		Objectives: opts.Objectives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := counter("items_processed_total", "Number of items processed")
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := counter("items_failed_total", "Number of items that failed")
+	if err != nil {
+		return nil, err
+	}
+
+	skipped, err := counter("items_skipped_total", "Number of items skipped")
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := gauge("running", "Number of runs in progress")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		LastStart:   lastStart,
+		LastSuccess: lastSuccess,
+		LastFailure: lastFailure,
+		Duration:    duration,
+		Processed:   processed,
+		Failed:      failed,
+		Skipped:     skipped,
+		Running:     running,
+		opts:        opts,
+	}, nil
+}
+
+// Register registers the Job strategy with the Prometheus DefaultRegisterer.
+func (j Job) Register() error {
+	err := RegisterStrategyFields(j)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// JobRun is a run of a job, counting the items it handles.
+type JobRun struct {
+	job    *Job
+	labels []string
+}
+
+// Processed counts n items as processed.
+func (r *JobRun) Processed(n int) {
+	r.job.Processed.WithLabelValues(r.labels...).Add(float64(n))
+}
+
+// Failed counts n items as failed.
+func (r *JobRun) Failed(n int) {
+	r.job.Failed.WithLabelValues(r.labels...).Add(float64(n))
+}
+
+// Skipped counts n items as skipped.
+func (r *JobRun) Skipped(n int) {
+	r.job.Skipped.WithLabelValues(r.labels...).Add(float64(n))
+}
+
+// Run runs fn as a run of the job and returns its error. The run fails if
+// fn returns an error or panics, in which case the panic is recorded and
+// re-raised.
+func (j *Job) Run(ctx context.Context, fn func(ctx context.Context, run *JobRun) error, labels ...string) (err error) {
+	start := j.opts.Now()
+	j.LastStart.WithLabelValues(labels...).Set(unixSeconds(start))
+	j.Running.WithLabelValues(labels...).Inc()
+
+	succeeded := false
+	defer func() {
+		end := j.opts.Now()
+
+		j.Running.WithLabelValues(labels...).Dec()
+		j.Duration.Observe(end.Sub(start).Seconds(), labels...)
+		if succeeded {
+			j.LastSuccess.WithLabelValues(labels...).Set(unixSeconds(end))
+		} else {
+			j.LastFailure.WithLabelValues(labels...).Set(unixSeconds(end))
+		}
+	}()
+
+	err = fn(ctx, &JobRun{job: j, labels: labels})
+	succeeded = err == nil
+
+	return err
+}
+
+// Pusher returns a Pusher of the job metrics to the Pushgateway at url,
+// grouped under the job name. Add groupings before pushing with Add or
+// AddContext, e.g.:
+//
+//	err := job.Pusher(url, "backup").Grouping("instance", host).AddContext(ctx)
+//
+// Add only replaces the metrics it pushes, and a failed run pushes no last
+// success timestamp, so the one of the previous successful run is kept.
+// Push and PushContext replace the whole group and lose it.
+func (j Job) Pusher(url, name string) *push.Pusher {
+	pusher := push.New(url, name)
+	for _, c := range j.collectors() {
+		pusher.Collector(c)
+	}
+
+	return pusher
+}
+
+// WriteTextfile writes the job metrics to filename for the textfile
+// collector of the node exporter. The metrics are merged with the ones
+// already in filename, so the last success timestamp of a previous run is
+// kept when the run fails. The file is written to a temporary file first
+// and renamed, so the collector never reads a partial file.
+func (j Job) WriteTextfile(filename string) error {
+	registry := prometheus.NewRegistry()
+	for _, c := range j.collectors() {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return prometheus.WriteToTextfile(filename, prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := registry.Gather()
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeTextfile(filename, families)
+	}))
+}
+
+// mergeTextfile returns families merged with the metric families of
+// filename. Series of filename are kept unless families have a series of
+// the same name and labels.
+func mergeTextfile(filename string, families []*dto.MetricFamily) ([]*dto.MetricFamily, error) {
+	f, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return families, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var parser expfmt.TextParser
+	previous, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	byName := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	for name, old := range previous {
+		family, ok := byName[name]
+		if !ok {
+			families = append(families, old)
+
+			continue
+		}
+
+		written := map[string]bool{}
+		for _, m := range family.GetMetric() {
+			written[labelPairsKey(m.GetLabel())] = true
+		}
+		for _, m := range old.GetMetric() {
+			if !written[labelPairsKey(m.GetLabel())] {
+				family.Metric = append(family.Metric, m)
+			}
+		}
+	}
+
+	sort.Slice(families, func(i, k int) bool { return families[i].GetName() < families[k].GetName() })
+
+	return families, nil
+}
+
+// labelPairsKey returns a key identifying a set of label pairs, whatever
+// their order.
+func labelPairsKey(pairs []*dto.LabelPair) string {
+	key := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		key = append(key, pair.GetName()+"="+pair.GetValue())
+	}
+	sort.Strings(key)
+
+	return strings.Join(key, "\xff")
+}
+
+func (j Job) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		j.LastStart,
+		j.LastSuccess,
+		j.LastFailure,
+		j.Duration.Histogram,
+		j.Duration.Summary,
+		j.Processed,
+		j.Failed,
+		j.Skipped,
+		j.Running,
+	}
+}
+
+// Opts returns the options the Job strategy was created with.
+func (j Job) Opts() JobOpts {
+	return j.opts
+}
+
+func (j Job) LastStartMetricName() string {
+	return fmt.Sprintf("%s_last_start_timestamp_seconds", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) LastSuccessMetricName() string {
+	return fmt.Sprintf("%s_last_success_timestamp_seconds", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) LastFailureMetricName() string {
+	return fmt.Sprintf("%s_last_failure_timestamp_seconds", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) DurationMetricName() string {
+	return getJobDurationMetricName(j.opts)
+}
+
+func (j Job) ProcessedMetricName() string {
+	return fmt.Sprintf("%s_items_processed_total", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) FailedMetricName() string {
+	return fmt.Sprintf("%s_items_failed_total", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) SkippedMetricName() string {
+	return fmt.Sprintf("%s_items_skipped_total", getJobMetricPrefix(j.opts))
+}
+
+func (j Job) RunningMetricName() string {
+	return fmt.Sprintf("%s_running", getJobMetricPrefix(j.opts))
+}
+
+func getJobMetricPrefix(opts JobOpts) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+
+	return "job"
+}
+
+func getJobDurationMetricName(opts JobOpts) string {
+	return fmt.Sprintf("%s_duration_seconds", getJobMetricPrefix(opts))
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
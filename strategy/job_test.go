@@ -0,0 +1,269 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJob(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts JobOpts
+		err  string
+	}{
+		"all good": {
+			opts: JobOpts{Namespace: "backup", Labels: []string{"task"}},
+		},
+		"missing namespace": {
+			opts: JobOpts{Labels: []string{"task"}},
+			err:  "JobOpts.Namespace",
+		},
+		"missing labels": {
+			opts: JobOpts{Namespace: "backup"},
+			err:  "JobOpts.Labels",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewJob(tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestJobMetricNames(t *testing.T) {
+	t.Parallel()
+
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "job_last_start_timestamp_seconds", job.LastStartMetricName())
+	assert.Equal(t, "job_last_success_timestamp_seconds", job.LastSuccessMetricName())
+	assert.Equal(t, "job_last_failure_timestamp_seconds", job.LastFailureMetricName())
+	assert.Equal(t, "job_duration_seconds", job.DurationMetricName())
+	assert.Equal(t, "job_items_processed_total", job.ProcessedMetricName())
+	assert.Equal(t, "job_items_failed_total", job.FailedMetricName())
+	assert.Equal(t, "job_items_skipped_total", job.SkippedMetricName())
+	assert.Equal(t, "job_running", job.RunningMetricName())
+
+	named, err := NewJob(JobOpts{Namespace: "backup", Name: "nightly", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "nightly_running", named.RunningMetricName())
+	assert.Equal(t, "nightly_duration_seconds_hist", named.Duration.HistogramName())
+}
+
+func TestJobRun(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}, Now: clock.Now})
+	assert.NoError(t, err)
+
+	start := float64(clock.Now().Unix())
+
+	err = job.Run(context.Background(), func(ctx context.Context, run *JobRun) error {
+		assert.Equal(t, 1.0, testutil.ToFloat64(job.Running.WithLabelValues("db")))
+
+		run.Processed(10)
+		run.Failed(2)
+		run.Skipped(1)
+		clock.Advance(time.Minute)
+
+		return nil
+	}, "db")
+	assert.NoError(t, err)
+
+	assert.Equal(t, start, testutil.ToFloat64(job.LastStart.WithLabelValues("db")))
+	assert.Equal(t, start+60, testutil.ToFloat64(job.LastSuccess.WithLabelValues("db")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(job.Running.WithLabelValues("db")))
+	assert.Equal(t, 10.0, testutil.ToFloat64(job.Processed.WithLabelValues("db")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(job.Failed.WithLabelValues("db")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(job.Skipped.WithLabelValues("db")))
+	assert.Equal(t, 1, testutil.CollectAndCount(job.Duration.Histogram))
+	assert.Equal(t, 0, testutil.CollectAndCount(job.LastFailure))
+
+	boom := errors.New("boom")
+	clock.Advance(time.Minute)
+	err = job.Run(context.Background(), func(context.Context, *JobRun) error { return boom }, "db")
+	assert.ErrorIs(t, err, boom)
+
+	assert.Equal(t, start+120, testutil.ToFloat64(job.LastStart.WithLabelValues("db")))
+	assert.Equal(t, start+60, testutil.ToFloat64(job.LastSuccess.WithLabelValues("db")))
+	assert.Equal(t, start+120, testutil.ToFloat64(job.LastFailure.WithLabelValues("db")))
+}
+
+func TestJobRunPanic(t *testing.T) {
+	t.Parallel()
+
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = job.Run(context.Background(), func(context.Context, *JobRun) error { panic("boom") }, "db")
+	})
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(job.Running.WithLabelValues("db")))
+	assert.Equal(t, 1, testutil.CollectAndCount(job.LastFailure))
+	assert.Equal(t, 0, testutil.CollectAndCount(job.LastSuccess))
+}
+
+func TestJobPusher(t *testing.T) {
+	t.Parallel()
+
+	var method, path string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, job.Run(ctx, func(context.Context, *JobRun) error { return nil }, "db"))
+	assert.NoError(t, job.Pusher(server.URL, "backup").Grouping("instance", "host-1").AddContext(ctx))
+
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "/metrics/job/backup/instance/host-1", path)
+
+	// The next run, in a new process, fails: it pushes no last success
+	// timestamp, so Add leaves the one of the previous run in place.
+	failing, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.Error(t, failing.Run(ctx, func(context.Context, *JobRun) error { return errors.New("boom") }, "db"))
+	assert.NoError(t, failing.Pusher(server.URL, "backup").Grouping("instance", "host-1").AddContext(ctx))
+
+	assert.Len(t, bodies, 2)
+	assert.Contains(t, bodies[0], "backup_job_last_success_timestamp_seconds")
+	assert.NotContains(t, bodies[1], "backup_job_last_success_timestamp_seconds")
+	assert.Contains(t, bodies[1], "backup_job_last_failure_timestamp_seconds")
+}
+
+func TestJobWriteTextfile(t *testing.T) {
+	t.Parallel()
+
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, job.Run(context.Background(), func(_ context.Context, run *JobRun) error {
+		run.Processed(3)
+
+		return nil
+	}, "db"))
+
+	filename := filepath.Join(t.TempDir(), "backup.prom")
+	assert.NoError(t, job.WriteTextfile(filename))
+
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `backup_job_items_processed_total{task="db"} 3`)
+	assert.Contains(t, string(data), `backup_job_running{task="db"} 0`)
+}
+
+func TestJobWriteTextfileMerges(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	filename := filepath.Join(t.TempDir(), "backup.prom")
+
+	run := func(fn func(ctx context.Context, run *JobRun) error, task string) {
+		t.Helper()
+
+		// Every run is a new process, with a new Job.
+		job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}, Now: clock.Now})
+		assert.NoError(t, err)
+
+		_ = job.Run(context.Background(), fn, task)
+		assert.NoError(t, job.WriteTextfile(filename))
+		clock.Advance(time.Hour)
+	}
+
+	run(func(_ context.Context, run *JobRun) error {
+		run.Processed(3)
+
+		return nil
+	}, "db")
+	run(func(_ context.Context, run *JobRun) error {
+		run.Processed(1)
+
+		return errors.New("boom")
+	}, "db")
+	run(func(context.Context, *JobRun) error { return nil }, "logs")
+
+	f, err := os.Open(filename)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(f)
+	assert.NoError(t, err)
+
+	value := func(name, task string) float64 {
+		t.Helper()
+
+		for _, m := range families[name].GetMetric() {
+			if m.GetLabel()[0].GetValue() != task {
+				continue
+			}
+			if m.GetGauge() != nil {
+				return m.GetGauge().GetValue()
+			}
+
+			return m.GetCounter().GetValue()
+		}
+		t.Fatalf("no %s{task=%q}", name, task)
+
+		return 0
+	}
+
+	start := float64(newFakeClock().Now().Unix())
+	assert.Equal(t, start, value("backup_job_last_success_timestamp_seconds", "db"))
+	assert.Equal(t, start+3600, value("backup_job_last_failure_timestamp_seconds", "db"))
+	assert.Equal(t, start+7200, value("backup_job_last_success_timestamp_seconds", "logs"))
+	assert.Equal(t, 1.0, value("backup_job_items_processed_total", "db"))
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestJobRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	job, err := NewJob(JobOpts{Namespace: "backup", Labels: []string{"task"}})
+	assert.NoError(t, err)
+	assert.NoError(t, job.Register())
+
+	assert.NoError(t, job.Run(context.Background(), func(context.Context, *JobRun) error { return nil }, "db"))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "backup_job_last_success_timestamp_seconds"))
+}
@@ -0,0 +1,355 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/promstrap/metrics"
+)
+
+var (
+	// ErrRetryMessage marks the error of a handler whose message is retried.
+	// Wrap it in the returned error, e.g. fmt.Errorf("%w: %w",
+	// ErrRetryMessage, err), so HandleMessages counts a retry.
+	ErrRetryMessage = errors.New("retry message")
+	// ErrDeadLetterMessage marks the error of a handler whose message is
+	// dead-lettered, so HandleMessages counts a dead letter.
+	ErrDeadLetterMessage = errors.New("dead-letter message")
+)
+
+// Messaging describes the metrics of message consumers and producers,
+// whatever the broker: the messages received, processed, failed, retried,
+// dead-lettered and published, how long handling them takes and how old
+// they are by then, and, as saturation, the messages in flight and the
+// consumer lag.
+type Messaging struct {
+	// The number of messages received.
+	Received *prometheus.CounterVec
+	// The number of messages processed successfully.
+	Processed *prometheus.CounterVec
+	// The number of messages whose processing failed, retried and
+	// dead-lettered ones included.
+	Failed *prometheus.CounterVec
+	// The number of messages retried.
+	Retried *prometheus.CounterVec
+	// The number of messages dead-lettered.
+	DeadLettered *prometheus.CounterVec
+	// The number of messages published.
+	Published *prometheus.CounterVec
+	// The number of messages that failed to publish.
+	PublishErrors *prometheus.CounterVec
+	// Distributions of the amount of time processing each message takes.
+	Duration *Distribution
+	// Distributions of the time between a message being produced and its
+	// processing being done.
+	Lag *Distribution
+	// The number of messages being processed.
+	InFlight *prometheus.GaugeVec
+	// The number of messages produced but not yet consumed, as reported by
+	// the broker.
+	ConsumerLag *prometheus.GaugeVec
+
+	opts MessagingOpts
+}
+
+// MessagingOpts is the options to create a Messaging strategy.
+type MessagingOpts struct {
+	Namespace string `validate:"required"`
+	// Name is the prefix of the messaging metrics. If not specified,
+	// defaults to "messaging".
+	Name string
+	// Labels are the labels to attach to every metric, e.g. "topic".
+	Labels []string `validate:"required"`
+	// Buckets defines the buckets of the processing duration histogram. If
+	// not specified, defaults to the Prometheus default buckets.
+	Buckets []float64
+	// LagBuckets defines the buckets of the end-to-end lag histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	LagBuckets []float64
+	// Objectives defines the summary quantile rank estimates with their
+	// respective absolute error.
+	Objectives map[float64]float64
+	// Now returns the current time. If not specified, defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewMessaging creates a Messaging strategy.
+func NewMessaging(opts MessagingOpts) (*Messaging, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	counter := func(name, help string) (*prometheus.CounterVec, error) {
+		return metrics.NewCounterWithLabels(metrics.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s", getMessagingMetricPrefix(opts), name),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	gauge := func(name, help string) (*prometheus.GaugeVec, error) {
+		return metrics.NewGaugeWithLabels(metrics.GaugeOpts{
+			Namespace: opts.Namespace,
+			Name:      fmt.Sprintf("%s_%s", getMessagingMetricPrefix(opts), name),
+			Help:      help,
+			Labels:    opts.Labels,
+		})
+	}
+
+	received, err := counter("received_total", "Number of messages received")
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := counter("processed_total", "Number of messages processed successfully")
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := counter("failed_total", "Number of messages whose processing failed")
+	if err != nil {
+		return nil, err
+	}
+
+	retried, err := counter("retried_total", "Number of messages retried")
+	if err != nil {
+		return nil, err
+	}
+
+	deadLettered, err := counter("dead_lettered_total", "Number of messages dead-lettered")
+	if err != nil {
+		return nil, err
+	}
+
+	published, err := counter("published_total", "Number of messages published")
+	if err != nil {
+		return nil, err
+	}
+
+	publishErrors, err := counter("publish_errors_total", "Number of messages that failed to publish")
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := NewDistribution(DistributionOpts{
+		Namespace:  opts.Namespace,
+		Name:       getMessagingDurationMetricName(opts),
+		Help:       "Duration of message processing in seconds",
+		Labels:     opts.Labels,
+		Buckets:    opts.Buckets,
+		Objectives: opts.Objectives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lag, err := NewDistribution(DistributionOpts{
+		Namespace:  opts.Namespace,
+		Name:       getMessagingLagMetricName(opts),
+		Help:       "Time from a message being produced to its processing being done in seconds",
+		Labels:     opts.Labels,
+		Buckets:    opts.LagBuckets,
+		Objectives: opts.Objectives,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inFlight, err := gauge("in_flight_messages", "Number of messages being processed")
+	if err != nil {
+		return nil, err
+	}
+
+	consumerLag, err := gauge("consumer_lag_messages", "Number of messages produced but not yet consumed")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Messaging{
+		Received:      received,
+		Processed:     processed,
+		Failed:        failed,
+		Retried:       retried,
+		DeadLettered:  deadLettered,
+		Published:     published,
+		PublishErrors: publishErrors,
+		Duration:      duration,
+		Lag:           lag,
+		InFlight:      inFlight,
+		ConsumerLag:   consumerLag,
+		opts:          opts,
+	}, nil
+}
+
+// Register registers the Messaging strategy with the Prometheus
+// DefaultRegisterer.
+func (m Messaging) Register() error {
+	err := RegisterStrategyFields(m)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ObserveRetry records that a message is retried, for consumers deciding
+// retries outside of their handler.
+func (m *Messaging) ObserveRetry(labels ...string) {
+	m.Retried.WithLabelValues(labels...).Inc()
+}
+
+// ObserveDeadLetter records that a message is dead-lettered, for consumers
+// deciding dead letters outside of their handler.
+func (m *Messaging) ObserveDeadLetter(labels ...string) {
+	m.DeadLettered.WithLabelValues(labels...).Inc()
+}
+
+// SetConsumerLag records the number of messages produced but not yet
+// consumed, e.g. the difference between the high watermark and the
+// committed offset of a Kafka partition.
+func (m *Messaging) SetConsumerLag(messages float64, labels ...string) {
+	m.ConsumerLag.WithLabelValues(labels...).Set(messages)
+}
+
+// Publish calls fn to publish a message and records it as published, or as
+// failing to publish when fn returns an error. It returns the error of fn.
+func (m *Messaging) Publish(ctx context.Context, fn func(ctx context.Context) error, labels ...string) error {
+	if err := fn(ctx); err != nil {
+		m.PublishErrors.WithLabelValues(labels...).Inc()
+
+		return err
+	}
+
+	m.Published.WithLabelValues(labels...).Inc()
+
+	return nil
+}
+
+// HandleMessages wraps handler so that every message it handles is recorded
+// with m: it is counted as received, in flight while handler runs, then as
+// processed or failed, and timed into the Duration. Errors wrapping
+// ErrRetryMessage or ErrDeadLetterMessage also count a retry or a dead
+// letter. A handler that panics counts a failure before the panic is
+// re-raised.
+//
+// timestamp returns when a message was produced, for the end-to-end Lag. It
+// may be nil, or return the zero time, to skip the lag.
+func HandleMessages[T any](
+	m *Messaging,
+	timestamp func(msg T) time.Time,
+	handler func(ctx context.Context, msg T) error,
+	labels ...string,
+) func(ctx context.Context, msg T) error {
+	return func(ctx context.Context, msg T) (err error) {
+		start := m.opts.Now()
+		m.Received.WithLabelValues(labels...).Inc()
+		m.InFlight.WithLabelValues(labels...).Inc()
+
+		succeeded := false
+		defer func() {
+			end := m.opts.Now()
+
+			m.InFlight.WithLabelValues(labels...).Dec()
+			m.Duration.Observe(end.Sub(start).Seconds(), labels...)
+			if timestamp != nil {
+				if produced := timestamp(msg); !produced.IsZero() {
+					m.Lag.Observe(end.Sub(produced).Seconds(), labels...)
+				}
+			}
+
+			if succeeded {
+				m.Processed.WithLabelValues(labels...).Inc()
+
+				return
+			}
+
+			m.Failed.WithLabelValues(labels...).Inc()
+			switch {
+			case errors.Is(err, ErrDeadLetterMessage):
+				m.DeadLettered.WithLabelValues(labels...).Inc()
+			case errors.Is(err, ErrRetryMessage):
+				m.Retried.WithLabelValues(labels...).Inc()
+			}
+		}()
+
+		err = handler(ctx, msg)
+		succeeded = err == nil
+
+		return err
+	}
+}
+
+// Opts returns the options the Messaging strategy was created with.
+func (m Messaging) Opts() MessagingOpts {
+	return m.opts
+}
+
+func (m Messaging) ReceivedMetricName() string {
+	return fmt.Sprintf("%s_received_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) ProcessedMetricName() string {
+	return fmt.Sprintf("%s_processed_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) FailedMetricName() string {
+	return fmt.Sprintf("%s_failed_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) RetriedMetricName() string {
+	return fmt.Sprintf("%s_retried_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) DeadLetteredMetricName() string {
+	return fmt.Sprintf("%s_dead_lettered_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) PublishedMetricName() string {
+	return fmt.Sprintf("%s_published_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) PublishErrorsMetricName() string {
+	return fmt.Sprintf("%s_publish_errors_total", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) DurationMetricName() string {
+	return getMessagingDurationMetricName(m.opts)
+}
+
+func (m Messaging) LagMetricName() string {
+	return getMessagingLagMetricName(m.opts)
+}
+
+func (m Messaging) InFlightMetricName() string {
+	return fmt.Sprintf("%s_in_flight_messages", getMessagingMetricPrefix(m.opts))
+}
+
+func (m Messaging) ConsumerLagMetricName() string {
+	return fmt.Sprintf("%s_consumer_lag_messages", getMessagingMetricPrefix(m.opts))
+}
+
+func getMessagingMetricPrefix(opts MessagingOpts) string {
+	if opts.Name != "" {
+		return opts.Name
+	}
+
+	return "messaging"
+}
+
+func getMessagingDurationMetricName(opts MessagingOpts) string {
+	return fmt.Sprintf("%s_processing_duration_seconds", getMessagingMetricPrefix(opts))
+}
+
+func getMessagingLagMetricName(opts MessagingOpts) string {
+	return fmt.Sprintf("%s_end_to_end_lag_seconds", getMessagingMetricPrefix(opts))
+}
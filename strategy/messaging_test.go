@@ -0,0 +1,177 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMessage struct {
+	body     string
+	produced time.Time
+}
+
+func TestNewMessaging(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts MessagingOpts
+		err  string
+	}{
+		"all good": {
+			opts: MessagingOpts{Namespace: "orders", Labels: []string{"topic"}},
+		},
+		"missing namespace": {
+			opts: MessagingOpts{Labels: []string{"topic"}},
+			err:  "MessagingOpts.Namespace",
+		},
+		"missing labels": {
+			opts: MessagingOpts{Namespace: "orders"},
+			err:  "MessagingOpts.Labels",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewMessaging(tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMessagingMetricNames(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewMessaging(MessagingOpts{Namespace: "orders", Labels: []string{"topic"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "messaging_received_total", m.ReceivedMetricName())
+	assert.Equal(t, "messaging_processed_total", m.ProcessedMetricName())
+	assert.Equal(t, "messaging_failed_total", m.FailedMetricName())
+	assert.Equal(t, "messaging_retried_total", m.RetriedMetricName())
+	assert.Equal(t, "messaging_dead_lettered_total", m.DeadLetteredMetricName())
+	assert.Equal(t, "messaging_published_total", m.PublishedMetricName())
+	assert.Equal(t, "messaging_publish_errors_total", m.PublishErrorsMetricName())
+	assert.Equal(t, "messaging_processing_duration_seconds", m.DurationMetricName())
+	assert.Equal(t, "messaging_end_to_end_lag_seconds", m.LagMetricName())
+	assert.Equal(t, "messaging_in_flight_messages", m.InFlightMetricName())
+	assert.Equal(t, "messaging_consumer_lag_messages", m.ConsumerLagMetricName())
+
+	named, err := NewMessaging(MessagingOpts{Namespace: "orders", Name: "kafka", Labels: []string{"topic"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "kafka_received_total", named.ReceivedMetricName())
+	assert.Equal(t, "kafka_end_to_end_lag_seconds_hist", named.Lag.HistogramName())
+}
+
+func TestHandleMessages(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	m, err := NewMessaging(MessagingOpts{Namespace: "orders", Labels: []string{"topic"}, Now: clock.Now})
+	assert.NoError(t, err)
+
+	handler := HandleMessages(m, func(msg testMessage) time.Time { return msg.produced },
+		func(ctx context.Context, msg testMessage) error {
+			assert.Equal(t, 1.0, testutil.ToFloat64(m.InFlight.WithLabelValues("created")))
+			clock.Advance(time.Second)
+
+			switch msg.body {
+			case "retry":
+				return fmt.Errorf("%w: unavailable", ErrRetryMessage)
+			case "poison":
+				return fmt.Errorf("%w: malformed", ErrDeadLetterMessage)
+			case "fail":
+				return errors.New("boom")
+			default:
+				return nil
+			}
+		}, "created")
+
+	produced := clock.Now().Add(-time.Minute)
+	assert.NoError(t, handler(context.Background(), testMessage{body: "ok", produced: produced}))
+	assert.ErrorIs(t, handler(context.Background(), testMessage{body: "retry"}), ErrRetryMessage)
+	assert.ErrorIs(t, handler(context.Background(), testMessage{body: "poison"}), ErrDeadLetterMessage)
+	assert.Error(t, handler(context.Background(), testMessage{body: "fail"}))
+
+	assert.Equal(t, 4.0, testutil.ToFloat64(m.Received.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Processed.WithLabelValues("created")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.Failed.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Retried.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DeadLettered.WithLabelValues("created")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.InFlight.WithLabelValues("created")))
+
+	// Only the message with a timestamp has a lag, a minute and a second.
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(m.Lag.Histogram))
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	histogram := families[0].GetMetric()[0].GetHistogram()
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+	assert.Equal(t, 61.0, histogram.GetSampleSum())
+}
+
+func TestHandleMessagesPanic(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewMessaging(MessagingOpts{Namespace: "orders", Labels: []string{"topic"}})
+	assert.NoError(t, err)
+
+	handler := HandleMessages(m, nil, func(context.Context, string) error { panic("boom") }, "created")
+
+	assert.PanicsWithValue(t, "boom", func() { _ = handler(context.Background(), "msg") })
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Failed.WithLabelValues("created")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.InFlight.WithLabelValues("created")))
+}
+
+func TestMessagingPublish(t *testing.T) {
+	t.Parallel()
+
+	m, err := NewMessaging(MessagingOpts{Namespace: "orders", Labels: []string{"topic"}})
+	assert.NoError(t, err)
+
+	boom := errors.New("boom")
+	assert.NoError(t, m.Publish(context.Background(), func(context.Context) error { return nil }, "created"))
+	assert.ErrorIs(t, m.Publish(context.Background(), func(context.Context) error { return boom }, "created"), boom)
+
+	m.SetConsumerLag(42, "created")
+	m.ObserveRetry("created")
+	m.ObserveDeadLetter("created")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Published.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.PublishErrors.WithLabelValues("created")))
+	assert.Equal(t, 42.0, testutil.ToFloat64(m.ConsumerLag.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Retried.WithLabelValues("created")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DeadLettered.WithLabelValues("created")))
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestMessagingRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	m, err := NewMessaging(MessagingOpts{Namespace: "orders", Labels: []string{"topic"}})
+	assert.NoError(t, err)
+	assert.NoError(t, m.Register())
+
+	m.SetConsumerLag(1, "created")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "orders_messaging_consumer_lag_messages"))
+}
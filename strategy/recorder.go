@@ -0,0 +1,83 @@
+package strategy
+
+// REDRecorder describes what a RED strategy records. Business logic should
+// depend on it rather than on *RED so that instrumentation can be swapped
+// for a no-op or a recording fake in unit tests.
+type REDRecorder interface {
+	// ObserveRequest records that a request took place.
+	ObserveRequest(labels ...string)
+	// ObserveError records that a request failed.
+	ObserveError(labels ...string)
+	// ObserveDuration records how long a request took in seconds.
+	ObserveDuration(seconds float64, labels ...string)
+}
+
+// USERecorder describes what a USE strategy records.
+type USERecorder interface {
+	// SetUtilization records how busy the resource is.
+	SetUtilization(value float64, labels ...string)
+	// SetSaturation records how much extra work is queued (or denied).
+	SetSaturation(value float64, labels ...string)
+	// ObserveError records that the resource failed to service work.
+	ObserveError(labels ...string)
+}
+
+// FGSRecorder describes what a FourGoldenSignals strategy records.
+type FGSRecorder interface {
+	// ObserveLatency records how long a request took in seconds.
+	ObserveLatency(seconds float64, labels ...string)
+	// ObserveTraffic records that a request took place.
+	ObserveTraffic(labels ...string)
+	// ObserveError records that a request failed.
+	ObserveError(labels ...string)
+	// SetSaturation records how "full" the service is.
+	SetSaturation(value float64, labels ...string)
+}
+
+var (
+	_ REDRecorder = (*RED)(nil)
+	_ REDRecorder = NoopRED{}
+	_ USERecorder = (*USE)(nil)
+	_ USERecorder = NoopUSE{}
+	_ FGSRecorder = (*FourGoldenSignals)(nil)
+	_ FGSRecorder = NoopFGS{}
+)
+
+// NoopRED is a REDRecorder that records nothing.
+type NoopRED struct{}
+
+// ObserveRequest does nothing.
+func (NoopRED) ObserveRequest(...string) {}
+
+// ObserveError does nothing.
+func (NoopRED) ObserveError(...string) {}
+
+// ObserveDuration does nothing.
+func (NoopRED) ObserveDuration(float64, ...string) {}
+
+// NoopUSE is a USERecorder that records nothing.
+type NoopUSE struct{}
+
+// SetUtilization does nothing.
+func (NoopUSE) SetUtilization(float64, ...string) {}
+
+// SetSaturation does nothing.
+func (NoopUSE) SetSaturation(float64, ...string) {}
+
+// ObserveError does nothing.
+func (NoopUSE) ObserveError(...string) {}
+
+// NoopFGS is a FGSRecorder that records nothing.
+type NoopFGS struct{}
+
+// ObserveLatency does nothing.
+func (NoopFGS) ObserveLatency(float64, ...string) {}
+
+// ObserveTraffic does nothing.
+func (NoopFGS) ObserveTraffic(...string) {}
+
+// ObserveError does nothing.
+func (NoopFGS) ObserveError(...string) {}
+
+// SetSaturation does nothing.
+func (NoopFGS) SetSaturation(float64, ...string) {}
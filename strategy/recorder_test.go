@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREDRecorder(t *testing.T) {
+	t.Parallel()
+
+	red, err := NewRED(REDOpts{
+		Namespace: "test",
+		RequestsOpt: REDRequestsOpt{
+			RequestType:   "http",
+			RequestLabels: []string{"path", "verb"},
+		},
+		ErrorsOpt: REDErrorsOpt{
+			ErrorLabels: []string{"error"},
+		},
+		DurationOpt: REDDurationOpt{
+			DurationLabels: []string{"path"},
+		},
+	})
+	assert.NoError(t, err)
+
+	var recorder REDRecorder = red
+	recorder.ObserveRequest("/happy", "GET")
+	recorder.ObserveRequest("/happy", "GET")
+	recorder.ObserveError("boom")
+	recorder.ObserveDuration(0.5, "/happy")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(red.Requests.WithLabelValues("/happy", "GET")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("boom")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Histogram))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Summary))
+}
+
+func TestUSERecorder(t *testing.T) {
+	t.Parallel()
+
+	use, err := NewUSE(USEOpts{
+		Namespace: "test",
+		UtilizationOpt: USEUtilizationOpt{
+			UtilizationName:   "cpu_utilization_ratio",
+			UtilizationHelp:   "CPU utilization",
+			UtilizationLabels: []string{"cpu"},
+		},
+		SaturationOpt: USESaturationOpt{
+			SaturationName:   "cpu_saturation_load",
+			SaturationHelp:   "CPU saturation",
+			SaturationLabels: []string{"cpu"},
+		},
+		ErrorsOpt: USEErrorsOpt{
+			ErrorLabels: []string{"error"},
+		},
+	})
+	assert.NoError(t, err)
+
+	var recorder USERecorder = use
+	recorder.SetUtilization(0.75, "0")
+	recorder.SetSaturation(3, "0")
+	recorder.ObserveError("throttled")
+
+	assert.Equal(t, 0.75, testutil.ToFloat64(use.Utilization.WithLabelValues("0")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(use.Saturation.WithLabelValues("0")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues("throttled")))
+}
+
+func TestFGSRecorder(t *testing.T) {
+	t.Parallel()
+
+	fgs, err := NewFourGoldenSignals(FourGoldenSignalsOpts{
+		Namespace: "test",
+		LatencyOpt: FGSLatencyOpt{
+			LatencyName:   "http_request_latency_seconds",
+			LatencyType:   "http",
+			LatencyHelp:   "Request latency in seconds",
+			LatencyLabels: []string{"method"},
+		},
+		TrafficOpt: FGSTrafficOpt{
+			TrafficName:   "http_requests_total",
+			TrafficType:   "http",
+			TrafficHelp:   "Total number of requests",
+			TrafficLabels: []string{"method"},
+		},
+		ErrorsOpt: FGSErrorsOpt{
+			ErrorHelp:   "Number of errors",
+			ErrorLabels: []string{"type"},
+		},
+		SaturationOpt: FGSSaturationOpt{
+			SaturationName:   "memory_heap_saturation_bytes",
+			SaturationHelp:   "Memory heap usage",
+			SaturationLabels: []string{"type"},
+		},
+	})
+	assert.NoError(t, err)
+
+	var recorder FGSRecorder = fgs
+	recorder.ObserveLatency(0.25, "GET")
+	recorder.ObserveTraffic("GET")
+	recorder.ObserveError("timeout")
+	recorder.SetSaturation(1024, "heap")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(fgs.Latency.Histogram))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Traffic.WithLabelValues("GET")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fgs.Errors.WithLabelValues("timeout")))
+	assert.Equal(t, 1024.0, testutil.ToFloat64(fgs.Saturation.WithLabelValues("heap")))
+}
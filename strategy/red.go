@@ -120,6 +120,27 @@ func (r RED) Register() error {
 	return nil
 }
 
+// ObserveRequest increments the requests counter.
+func (r RED) ObserveRequest(labels ...string) {
+	r.Requests.WithLabelValues(labels...).Inc()
+}
+
+// ObserveError increments the errors counter.
+func (r RED) ObserveError(labels ...string) {
+	r.Errors.WithLabelValues(labels...).Inc()
+}
+
+// ObserveDuration records the duration of a request in seconds with both the
+// histogram and the summary.
+func (r RED) ObserveDuration(seconds float64, labels ...string) {
+	r.Duration.Observe(seconds, labels...)
+}
+
+// Opts returns the options the RED strategy was created with.
+func (r RED) Opts() REDOpts {
+	return r.opts
+}
+
 func (r RED) RequestMetricName() string {
 	return getREDRequestsMetricName(r.opts)
 }
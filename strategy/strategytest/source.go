@@ -0,0 +1,112 @@
+package strategytest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Source is an in-memory message source standing in for a broker, so that
+// consumers wrapped with strategy.HandleMessages can be tested end to end.
+// Messages are delivered in the order they are published. A message whose
+// handler fails with strategy.ErrRetryMessage is published again, one
+// failing with strategy.ErrDeadLetterMessage is kept as a dead letter, one
+// failing with any other error is kept as failed, neither acknowledged nor
+// retried, and the others are acknowledged. The zero value is ready to use.
+type Source[T any] struct {
+	mu          sync.Mutex
+	pending     []T
+	acked       []T
+	deadLetters []T
+	failed      []T
+}
+
+// Publish adds msgs to the messages to deliver.
+func (s *Source[T]) Publish(msgs ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, msgs...)
+}
+
+// Drain delivers messages to handler one at a time until none is left,
+// retried ones included, or ctx is done, in which case it returns the error
+// of ctx.
+func (s *Source[T]) Drain(ctx context.Context, handler func(ctx context.Context, msg T) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		msg, ok := s.next()
+		if !ok {
+			return nil
+		}
+
+		err := handler(ctx, msg)
+
+		s.mu.Lock()
+		switch {
+		case err == nil:
+			s.acked = append(s.acked, msg)
+		case errors.Is(err, strategy.ErrRetryMessage):
+			s.pending = append(s.pending, msg)
+		case errors.Is(err, strategy.ErrDeadLetterMessage):
+			s.deadLetters = append(s.deadLetters, msg)
+		default:
+			s.failed = append(s.failed, msg)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Lag returns the number of messages left to deliver, like the consumer lag
+// of a broker.
+func (s *Source[T]) Lag() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Acked returns the messages handled successfully in order.
+func (s *Source[T]) Acked() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]T(nil), s.acked...)
+}
+
+// DeadLetters returns the messages whose handler failed with
+// strategy.ErrDeadLetterMessage in order.
+func (s *Source[T]) DeadLetters() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]T(nil), s.deadLetters...)
+}
+
+// Failed returns the messages whose handler failed with any other error in
+// order. They were neither acknowledged nor retried.
+func (s *Source[T]) Failed() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]T(nil), s.failed...)
+}
+
+func (s *Source[T]) next() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var msg T
+	if len(s.pending) == 0 {
+		return msg, false
+	}
+
+	msg, s.pending = s.pending[0], s.pending[1:]
+
+	return msg, true
+}
@@ -0,0 +1,60 @@
+package strategytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	var source Source[string]
+	source.Publish("ok", "flaky", "poison", "broken")
+	assert.Equal(t, 4, source.Lag())
+
+	attempts := map[string]int{}
+	err := source.Drain(context.Background(), func(_ context.Context, msg string) error {
+		attempts[msg]++
+
+		switch {
+		case msg == "flaky" && attempts[msg] < 3:
+			return fmt.Errorf("%w: unavailable", strategy.ErrRetryMessage)
+		case msg == "poison":
+			return fmt.Errorf("%w: malformed", strategy.ErrDeadLetterMessage)
+		case msg == "broken":
+			return errors.New("unavailable")
+		default:
+			return nil
+		}
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, source.Lag())
+	assert.Equal(t, 3, attempts["flaky"])
+	assert.Equal(t, []string{"ok", "flaky"}, source.Acked())
+	assert.Equal(t, []string{"poison"}, source.DeadLetters())
+	assert.Equal(t, []string{"broken"}, source.Failed())
+}
+
+func TestSourceCanceled(t *testing.T) {
+	t.Parallel()
+
+	var source Source[int]
+	source.Publish(1, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := source.Drain(ctx, func(context.Context, int) error {
+		cancel()
+
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, []int{1}, source.Acked())
+	assert.Equal(t, 1, source.Lag())
+}
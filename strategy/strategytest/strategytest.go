@@ -0,0 +1,163 @@
+// Package strategytest provides recording fakes of the strategy recorders so
+// that instrumented code can be unit tested without a Prometheus registry.
+package strategytest
+
+import (
+	"sync"
+
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+var (
+	_ strategy.REDRecorder = (*RED)(nil)
+	_ strategy.USERecorder = (*USE)(nil)
+	_ strategy.FGSRecorder = (*FourGoldenSignals)(nil)
+)
+
+// Observation is a single call made to a recorder. Value is zero for calls
+// that only count an occurrence.
+type Observation struct {
+	Value  float64
+	Labels []string
+}
+
+// calls is a concurrency safe, append only list of observations.
+type calls struct {
+	mu           sync.Mutex
+	observations []Observation
+}
+
+func (c *calls) add(value float64, labels []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.observations = append(c.observations, Observation{
+		Value:  value,
+		Labels: append([]string(nil), labels...),
+	})
+}
+
+func (c *calls) list() []Observation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Observation(nil), c.observations...)
+}
+
+func (c *calls) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.observations = nil
+}
+
+// RED is a strategy.REDRecorder that stores every call for assertions.
+// The zero value is ready to use.
+type RED struct {
+	requests  calls
+	errors    calls
+	durations calls
+}
+
+// ObserveRequest records the call.
+func (r *RED) ObserveRequest(labels ...string) { r.requests.add(0, labels) }
+
+// ObserveError records the call.
+func (r *RED) ObserveError(labels ...string) { r.errors.add(0, labels) }
+
+// ObserveDuration records the call.
+func (r *RED) ObserveDuration(seconds float64, labels ...string) { r.durations.add(seconds, labels) }
+
+// Requests returns the recorded ObserveRequest calls in order.
+func (r *RED) Requests() []Observation { return r.requests.list() }
+
+// Errors returns the recorded ObserveError calls in order.
+func (r *RED) Errors() []Observation { return r.errors.list() }
+
+// Durations returns the recorded ObserveDuration calls in order.
+func (r *RED) Durations() []Observation { return r.durations.list() }
+
+// Reset forgets every recorded call.
+func (r *RED) Reset() {
+	r.requests.reset()
+	r.errors.reset()
+	r.durations.reset()
+}
+
+// USE is a strategy.USERecorder that stores every call for assertions.
+// The zero value is ready to use.
+type USE struct {
+	utilization calls
+	saturation  calls
+	errors      calls
+}
+
+// SetUtilization records the call.
+func (u *USE) SetUtilization(value float64, labels ...string) { u.utilization.add(value, labels) }
+
+// SetSaturation records the call.
+func (u *USE) SetSaturation(value float64, labels ...string) { u.saturation.add(value, labels) }
+
+// ObserveError records the call.
+func (u *USE) ObserveError(labels ...string) { u.errors.add(0, labels) }
+
+// Utilization returns the recorded SetUtilization calls in order.
+func (u *USE) Utilization() []Observation { return u.utilization.list() }
+
+// Saturation returns the recorded SetSaturation calls in order.
+func (u *USE) Saturation() []Observation { return u.saturation.list() }
+
+// Errors returns the recorded ObserveError calls in order.
+func (u *USE) Errors() []Observation { return u.errors.list() }
+
+// Reset forgets every recorded call.
+func (u *USE) Reset() {
+	u.utilization.reset()
+	u.saturation.reset()
+	u.errors.reset()
+}
+
+// FourGoldenSignals is a strategy.FGSRecorder that stores every call for
+// assertions. The zero value is ready to use.
+type FourGoldenSignals struct {
+	latency    calls
+	traffic    calls
+	errors     calls
+	saturation calls
+}
+
+// ObserveLatency records the call.
+func (f *FourGoldenSignals) ObserveLatency(seconds float64, labels ...string) {
+	f.latency.add(seconds, labels)
+}
+
+// ObserveTraffic records the call.
+func (f *FourGoldenSignals) ObserveTraffic(labels ...string) { f.traffic.add(0, labels) }
+
+// ObserveError records the call.
+func (f *FourGoldenSignals) ObserveError(labels ...string) { f.errors.add(0, labels) }
+
+// SetSaturation records the call.
+func (f *FourGoldenSignals) SetSaturation(value float64, labels ...string) {
+	f.saturation.add(value, labels)
+}
+
+// Latency returns the recorded ObserveLatency calls in order.
+func (f *FourGoldenSignals) Latency() []Observation { return f.latency.list() }
+
+// Traffic returns the recorded ObserveTraffic calls in order.
+func (f *FourGoldenSignals) Traffic() []Observation { return f.traffic.list() }
+
+// Errors returns the recorded ObserveError calls in order.
+func (f *FourGoldenSignals) Errors() []Observation { return f.errors.list() }
+
+// Saturation returns the recorded SetSaturation calls in order.
+func (f *FourGoldenSignals) Saturation() []Observation { return f.saturation.list() }
+
+// Reset forgets every recorded call.
+func (f *FourGoldenSignals) Reset() {
+	f.latency.reset()
+	f.traffic.reset()
+	f.errors.reset()
+	f.saturation.reset()
+}
@@ -0,0 +1,75 @@
+package strategytest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRED(t *testing.T) {
+	t.Parallel()
+
+	var red RED
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			red.ObserveRequest("/happy", "GET")
+		}()
+	}
+	wg.Wait()
+
+	red.ObserveError("boom")
+	red.ObserveDuration(0.5, "/happy")
+
+	assert.Len(t, red.Requests(), 10)
+	assert.Equal(t, []Observation{{Labels: []string{"boom"}}}, red.Errors())
+	assert.Equal(t, []Observation{{Value: 0.5, Labels: []string{"/happy"}}}, red.Durations())
+
+	red.Reset()
+	assert.Empty(t, red.Requests())
+	assert.Empty(t, red.Errors())
+	assert.Empty(t, red.Durations())
+}
+
+func TestUSE(t *testing.T) {
+	t.Parallel()
+
+	var use USE
+	use.SetUtilization(0.75, "cpu")
+	use.SetSaturation(3, "cpu")
+	use.ObserveError("throttled")
+
+	assert.Equal(t, []Observation{{Value: 0.75, Labels: []string{"cpu"}}}, use.Utilization())
+	assert.Equal(t, []Observation{{Value: 3, Labels: []string{"cpu"}}}, use.Saturation())
+	assert.Equal(t, []Observation{{Labels: []string{"throttled"}}}, use.Errors())
+}
+
+func TestFourGoldenSignals(t *testing.T) {
+	t.Parallel()
+
+	var fgs FourGoldenSignals
+	fgs.ObserveLatency(0.25, "GET")
+	fgs.ObserveTraffic("GET")
+	fgs.ObserveError("timeout")
+	fgs.SetSaturation(1024, "heap")
+
+	assert.Equal(t, []Observation{{Value: 0.25, Labels: []string{"GET"}}}, fgs.Latency())
+	assert.Equal(t, []Observation{{Labels: []string{"GET"}}}, fgs.Traffic())
+	assert.Equal(t, []Observation{{Labels: []string{"timeout"}}}, fgs.Errors())
+	assert.Equal(t, []Observation{{Value: 1024, Labels: []string{"heap"}}}, fgs.Saturation())
+}
+
+func TestLabelsAreCopied(t *testing.T) {
+	t.Parallel()
+
+	var red RED
+	labels := []string{"/happy", "GET"}
+	red.ObserveRequest(labels...)
+	labels[0] = "/sad"
+
+	assert.Equal(t, []string{"/happy", "GET"}, red.Requests()[0].Labels)
+}
@@ -133,6 +133,26 @@ func (u USE) Register() error {
 	return nil
 }
 
+// SetUtilization sets the utilization gauge.
+func (u USE) SetUtilization(value float64, labels ...string) {
+	u.Utilization.WithLabelValues(labels...).Set(value)
+}
+
+// SetSaturation sets the saturation gauge.
+func (u USE) SetSaturation(value float64, labels ...string) {
+	u.Saturation.WithLabelValues(labels...).Set(value)
+}
+
+// ObserveError increments the errors counter.
+func (u USE) ObserveError(labels ...string) {
+	u.Errors.WithLabelValues(labels...).Inc()
+}
+
+// Opts returns the options the USE strategy was created with.
+func (u USE) Opts() USEOpts {
+	return u.opts
+}
+
 // UtilizationMetricName returns the name of the utilization metric.
 func (u USE) UtilizationMetricName() string {
 	return getUSEUtilizationMetricName(u.opts)
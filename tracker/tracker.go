@@ -0,0 +1,163 @@
+// Package tracker records any function call with a RED strategy, for
+// operations that are not HTTP requests: cache refreshes, queue handlers,
+// calls through bespoke clients.
+//
+// The label values of every metric are derived from the label names the
+// strategy was created with:
+//
+//	operation, op                the operation name passed to Track
+//	outcome                      "error" when the call failed, "success" otherwise
+//	error, error_class, class    the class of the error, see Classifier, or "" on success
+package tracker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Classifier returns the class of an error. Classes are label values, so a
+// Classifier must return a small, bounded set of them.
+type Classifier func(err error) string
+
+// Panic is the class of calls that panicked.
+const Panic = "panic"
+
+// DefaultClassifier classifies context.Canceled as "canceled",
+// context.DeadlineExceeded as "timeout" and every other error as "error".
+func DefaultClassifier(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// Opts is the options to create a Tracker.
+type Opts struct {
+	// Classify returns the class of the errors calls fail with. If not
+	// specified, defaults to DefaultClassifier.
+	Classify Classifier
+}
+
+// call holds what label values are derived from.
+type call struct {
+	operation string
+	failed    bool
+	class     string
+}
+
+var labelValues = map[string]func(call) string{
+	"operation":   func(c call) string { return c.operation },
+	"op":          func(c call) string { return c.operation },
+	"outcome":     outcome,
+	"error":       func(c call) string { return c.class },
+	"error_class": func(c call) string { return c.class },
+	"class":       func(c call) string { return c.class },
+}
+
+func outcome(c call) string {
+	if c.failed {
+		return "error"
+	}
+
+	return "success"
+}
+
+// Tracker records function calls with a RED strategy.
+type Tracker struct {
+	red            *strategy.RED
+	classify       Classifier
+	requestLabels  labels.Labeler[call]
+	errorLabels    labels.Labeler[call]
+	durationLabels labels.Labeler[call]
+}
+
+// New creates a Tracker recording calls with a RED strategy. It fails if a
+// label of the strategy is not supported.
+func New(red *strategy.RED, opts Opts) (*Tracker, error) {
+	o := red.Opts()
+
+	requestLabels, err := labels.New(labelValues, o.RequestsOpt.RequestLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	durationLabels, err := labels.New(labelValues, o.DurationOpt.DurationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	classify := opts.Classify
+	if classify == nil {
+		classify = DefaultClassifier
+	}
+
+	return &Tracker{
+		red:            red,
+		classify:       classify,
+		requestLabels:  requestLabels,
+		errorLabels:    errorLabels,
+		durationLabels: durationLabels,
+	}, nil
+}
+
+// Track calls fn with ctx and records the call as op: it counts the call,
+// times it into the Distribution and, when fn returns an error, counts an
+// error with the class of the error. A call that panics is recorded as an
+// error of class Panic before the panic is re-raised. Track returns the error
+// of fn.
+func (t *Tracker) Track(ctx context.Context, op string, fn func(context.Context) error) error {
+	_, err := Value(ctx, t, op, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+
+	return err
+}
+
+// Value is Track for functions returning a value.
+func Value[T any](ctx context.Context, t *Tracker, op string, fn func(context.Context) (T, error)) (T, error) {
+	start := time.Now()
+	c := call{operation: op}
+
+	defer func() {
+		p := recover()
+		if p != nil {
+			c.failed = true
+			c.class = Panic
+		}
+
+		t.record(c, time.Since(start).Seconds())
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	v, err := fn(ctx)
+	if err != nil {
+		c.failed = true
+		c.class = t.classify(err)
+	}
+
+	return v, err
+}
+
+func (t *Tracker) record(c call, seconds float64) {
+	t.red.ObserveRequest(t.requestLabels.Values(c)...)
+	if c.failed {
+		t.red.ObserveError(t.errorLabels.Values(c)...)
+	}
+	t.red.ObserveDuration(seconds, t.durationLabels.Values(c)...)
+}
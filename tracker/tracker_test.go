@@ -0,0 +1,124 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRED(t *testing.T, errorLabels ...string) *strategy.RED {
+	t.Helper()
+
+	red, err := strategy.NewRED(strategy.REDOpts{
+		Namespace: "worker",
+		RequestsOpt: strategy.REDRequestsOpt{
+			RequestName:   "operations_total",
+			RequestType:   "operation",
+			RequestLabels: []string{"operation", "outcome"},
+		},
+		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorLabels: errorLabels,
+		},
+		DurationOpt: strategy.REDDurationOpt{
+			DurationName:   "operation_duration_seconds",
+			DurationLabels: []string{"op"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return red
+}
+
+func TestTrack(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "operation", "error_class")
+	tr, err := New(red, Opts{})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	assert.NoError(t, tr.Track(ctx, "refresh", func(context.Context) error { return nil }))
+	assert.ErrorIs(t, tr.Track(ctx, "refresh", func(context.Context) error { return boom }), boom)
+	assert.ErrorIs(t, tr.Track(ctx, "refresh", func(context.Context) error {
+		return fmt.Errorf("refresh: %w", context.DeadlineExceeded)
+	}), context.DeadlineExceeded)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_ = tr.Track(canceled, "refresh", func(ctx context.Context) error { return ctx.Err() })
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("refresh", "success")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(red.Requests.WithLabelValues("refresh", "error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("refresh", "error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("refresh", "timeout")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("refresh", "canceled")))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Histogram))
+	assert.Equal(t, 1, testutil.CollectAndCount(red.Duration.Summary))
+}
+
+func TestTrackClassifier(t *testing.T) {
+	t.Parallel()
+
+	notFound := errors.New("not found")
+
+	red := newTestRED(t, "class")
+	tr, err := New(red, Opts{
+		Classify: func(err error) string {
+			if errors.Is(err, notFound) {
+				return "not_found"
+			}
+			return "internal"
+		},
+	})
+	assert.NoError(t, err)
+
+	_ = tr.Track(context.Background(), "get", func(context.Context) error { return notFound })
+	_ = tr.Track(context.Background(), "get", func(context.Context) error { return errors.New("db down") })
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("internal")))
+}
+
+func TestTrackPanic(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "op", "error")
+	tr, err := New(red, Opts{})
+	assert.NoError(t, err)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = tr.Track(context.Background(), "handle", func(context.Context) error { panic("boom") })
+	})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("handle", "error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Errors.WithLabelValues("handle", Panic)))
+}
+
+func TestValue(t *testing.T) {
+	t.Parallel()
+
+	red := newTestRED(t, "op")
+	tr, err := New(red, Opts{})
+	assert.NoError(t, err)
+
+	v, err := Value(context.Background(), tr, "lookup", func(context.Context) (int, error) { return 42, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Equal(t, 1.0, testutil.ToFloat64(red.Requests.WithLabelValues("lookup", "success")))
+}
+
+func TestNewUnsupportedLabel(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(newTestRED(t, "tenant"), Opts{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,318 @@
+// Package workerpool is a bounded worker pool whose tasks drive a USE
+// strategy:
+//
+//	Utilization  busy workers over workers
+//	Saturation   tasks waiting for a worker, including blocked Submit calls
+//	Errors       tasks that failed or panicked
+//
+// and whose QueueWait distribution records how long tasks waited for a
+// worker. Panics of tasks are recovered and counted; they do not crash the
+// program.
+//
+// The label values of every metric are derived from the label names the
+// strategy was created with:
+//
+//	pool, name               the name of the pool
+//	reason, error, class     "failed" or "panic" for errors, "" otherwise
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/rabellamy/promstrap/internal/labels"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// ErrClosed is returned by Submit once the pool is shutting down.
+var ErrClosed = errors.New("workerpool: pool is closed")
+
+// The reasons a task is counted as an error.
+const (
+	ReasonFailed = "failed"
+	ReasonPanic  = "panic"
+)
+
+// Task is a unit of work. Its context is canceled once the task timed out,
+// or when Shutdown gives up waiting for the pool to drain.
+type Task func(ctx context.Context) error
+
+// Opts is the options to create a Pool.
+type Opts struct {
+	// Name is the name of the pool, the value of the pool label.
+	Name string `validate:"required"`
+	// Workers is the number of tasks run concurrently.
+	Workers int `validate:"gt=0"`
+	// QueueSize is the number of tasks that wait for a worker before Submit
+	// blocks.
+	QueueSize int `validate:"gte=0"`
+	// TaskTimeout bounds the context of every task. If not specified, tasks
+	// have no deadline.
+	TaskTimeout time.Duration `validate:"gte=0"`
+	// WaitBuckets defines the buckets of the QueueWait histogram. If not
+	// specified, defaults to the Prometheus default buckets.
+	WaitBuckets []float64
+}
+
+// state holds what label values are derived from.
+type state struct {
+	pool   string
+	reason string
+}
+
+var labelValues = map[string]func(state) string{
+	"pool":   func(s state) string { return s.pool },
+	"name":   func(s state) string { return s.pool },
+	"reason": func(s state) string { return s.reason },
+	"error":  func(s state) string { return s.reason },
+	"class":  func(s state) string { return s.reason },
+}
+
+// queued is a submitted task.
+type queued struct {
+	task      Task
+	submitted time.Time
+}
+
+// Pool runs submitted tasks on a fixed number of workers.
+type Pool struct {
+	// QueueWait is how long tasks waited for a worker in seconds,
+	// {namespace}_worker_pool_queue_wait_seconds, labelled by pool name.
+	// Once registered, it is shared by every pool of the namespace.
+	QueueWait *strategy.Distribution
+
+	use               *strategy.USE
+	opts              Opts
+	utilizationLabels []string
+	saturationLabels  []string
+	errorLabels       labels.Labeler[state]
+
+	tasks  chan queued
+	quit   chan struct{}
+	drain  chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	workers    sync.WaitGroup
+	submitters sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	busy   int
+	queued int
+}
+
+// New creates a Pool and starts its workers. It fails if a label of the
+// strategy is not supported.
+func New(use *strategy.USE, opts Opts) (*Pool, error) {
+	validate := validator.New()
+	if err := validate.Struct(opts); err != nil {
+		return nil, err
+	}
+
+	o := use.Opts()
+
+	utilizationLabels, err := labels.New(labelValues, o.UtilizationOpt.UtilizationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	saturationLabels, err := labels.New(labelValues, o.SaturationOpt.SaturationLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLabels, err := labels.New(labelValues, o.ErrorsOpt.ErrorLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	wait, err := strategy.NewDistribution(strategy.DistributionOpts{
+		Namespace: o.Namespace,
+		Name:      "worker_pool_queue_wait_seconds",
+		Help:      "Time tasks waited for a worker in seconds",
+		Labels:    []string{"pool"},
+		Buckets:   opts.WaitBuckets,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Pool{
+		QueueWait:         wait,
+		use:               use,
+		opts:              opts,
+		utilizationLabels: utilizationLabels.Values(state{pool: opts.Name}),
+		saturationLabels:  saturationLabels.Values(state{pool: opts.Name}),
+		errorLabels:       errorLabels,
+		tasks:             make(chan queued, opts.QueueSize),
+		quit:              make(chan struct{}),
+		drain:             make(chan struct{}),
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+
+	p.update(0, 0)
+
+	p.workers.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go p.work()
+	}
+
+	return p, nil
+}
+
+// Register registers the QueueWait distribution with the Prometheus
+// DefaultRegisterer, or shares the one another pool of the namespace
+// registered. The strategy is registered on its own.
+func (p *Pool) Register() error {
+	wait, err := p.QueueWait.RegisterShared()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.QueueWait = wait
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Submit queues task, blocking while the queue is full. It fails with the
+// error of ctx if ctx is done first, or with ErrClosed once the pool is
+// shutting down.
+func (p *Pool) Submit(ctx context.Context, task Task) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+
+		return ErrClosed
+	}
+	p.submitters.Add(1)
+	p.mu.Unlock()
+
+	defer p.submitters.Done()
+
+	p.update(0, 1)
+
+	select {
+	case p.tasks <- queued{task: task, submitted: time.Now()}:
+		return nil
+	case <-ctx.Done():
+		p.update(0, -1)
+
+		return ctx.Err()
+	case <-p.quit:
+		p.update(0, -1)
+
+		return ErrClosed
+	}
+}
+
+// Shutdown stops accepting tasks and waits for the queued and running ones
+// to complete. If ctx is done first, it cancels the context of the tasks
+// and returns the error of ctx.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.quit)
+	}
+	p.mu.Unlock()
+
+	// Once every Submit returned, nothing is sent anymore: workers drain
+	// the queue and exit.
+	p.submitters.Wait()
+
+	p.mu.Lock()
+	select {
+	case <-p.drain:
+	default:
+		close(p.drain)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+
+	for {
+		select {
+		case q := <-p.tasks:
+			p.run(q)
+		case <-p.drain:
+			for {
+				select {
+				case q := <-p.tasks:
+					p.run(q)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Pool) run(q queued) {
+	p.mu.Lock()
+	wait := p.QueueWait
+	p.mu.Unlock()
+
+	wait.Observe(time.Since(q.submitted).Seconds(), p.opts.Name)
+	p.update(1, -1)
+	defer p.update(-1, 0)
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.opts.TaskTimeout > 0 {
+		ctx, cancel = context.WithTimeout(p.ctx, p.opts.TaskTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.ctx)
+	}
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.use.ObserveError(p.errorLabels.Values(state{pool: p.opts.Name, reason: ReasonPanic})...)
+		}
+	}()
+
+	if err := q.task(ctx); err != nil {
+		p.use.ObserveError(p.errorLabels.Values(state{pool: p.opts.Name, reason: ReasonFailed})...)
+	}
+}
+
+// update moves the busy workers and queued tasks by the deltas and sets
+// utilization and saturation.
+func (p *Pool) update(busy, queued int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy += busy
+	p.queued += queued
+
+	p.use.SetUtilization(float64(p.busy)/float64(p.opts.Workers), p.utilizationLabels...)
+	p.use.SetSaturation(float64(p.queued), p.saturationLabels...)
+}
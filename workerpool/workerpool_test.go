@@ -0,0 +1,237 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUSE(t *testing.T, errorLabels ...string) *strategy.USE {
+	t.Helper()
+
+	use, err := strategy.NewUSE(strategy.USEOpts{
+		Namespace: "jobs",
+		UtilizationOpt: strategy.USEUtilizationOpt{
+			UtilizationName:   "worker_pool_utilization_ratio",
+			UtilizationHelp:   "Busy workers over workers",
+			UtilizationLabels: []string{"pool"},
+		},
+		SaturationOpt: strategy.USESaturationOpt{
+			SaturationName:   "worker_pool_saturation_pending_tasks",
+			SaturationHelp:   "Tasks waiting for a worker",
+			SaturationLabels: []string{"name"},
+		},
+		ErrorsOpt: strategy.USEErrorsOpt{
+			ErrorLabels: errorLabels,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return use
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		labels []string
+		opts   Opts
+		err    string
+	}{
+		"valid": {
+			labels: []string{"pool", "reason"},
+			opts:   Opts{Name: "emails", Workers: 2},
+		},
+		"missing name": {
+			labels: []string{"pool"},
+			opts:   Opts{Workers: 2},
+			err:    "Opts.Name",
+		},
+		"no workers": {
+			labels: []string{"pool"},
+			opts:   Opts{Name: "emails"},
+			err:    "Opts.Workers",
+		},
+		"unsupported label": {
+			labels: []string{"pool", "tenant"},
+			opts:   Opts{Name: "emails", Workers: 2},
+			err:    `label "tenant" is not one of`,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := New(newTestUSE(t, tc.labels...), tc.opts)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, p.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "pool", "reason")
+	p, err := New(use, Opts{Name: "emails", Workers: 2, QueueSize: 4})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	blocking := func(context.Context) error {
+		started <- struct{}{}
+		<-release
+
+		return nil
+	}
+
+	assert.NoError(t, p.Submit(ctx, blocking))
+	assert.NoError(t, p.Submit(ctx, blocking))
+	<-started
+	<-started
+
+	assert.NoError(t, p.Submit(ctx, func(context.Context) error { return errors.New("boom") }))
+	assert.NoError(t, p.Submit(ctx, func(context.Context) error { panic("boom") }))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Utilization.WithLabelValues("emails")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(use.Saturation.WithLabelValues("emails")))
+
+	close(release)
+	assert.NoError(t, p.Shutdown(ctx))
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(use.Utilization.WithLabelValues("emails")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(use.Saturation.WithLabelValues("emails")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues("emails", ReasonFailed)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues("emails", ReasonPanic)))
+	assert.Equal(t, 1, testutil.CollectAndCount(p.QueueWait.Histogram))
+}
+
+func TestSubmitCanceled(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "pool")
+	p, err := New(use, Opts{Name: "emails", Workers: 1})
+	assert.NoError(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	assert.NoError(t, p.Submit(context.Background(), func(context.Context) error {
+		close(started)
+		<-release
+
+		return nil
+	}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, p.Submit(ctx, func(context.Context) error { return nil }), context.DeadlineExceeded)
+	assert.Equal(t, 0.0, testutil.ToFloat64(use.Saturation.WithLabelValues("emails")))
+
+	close(release)
+	assert.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestShutdownDrains(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(newTestUSE(t, "pool"), Opts{Name: "emails", Workers: 1, QueueSize: 8})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	done := make(chan struct{}, 8)
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, p.Submit(ctx, func(context.Context) error {
+			done <- struct{}{}
+
+			return nil
+		}))
+	}
+
+	assert.NoError(t, p.Shutdown(ctx))
+	assert.Len(t, done, 8)
+	assert.ErrorIs(t, p.Submit(ctx, func(context.Context) error { return nil }), ErrClosed)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(newTestUSE(t, "pool"), Opts{Name: "emails", Workers: 1})
+	assert.NoError(t, err)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	assert.NoError(t, p.Submit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+
+		return ctx.Err()
+	}))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	<-canceled
+}
+
+func TestTaskTimeout(t *testing.T) {
+	t.Parallel()
+
+	use := newTestUSE(t, "reason")
+	p, err := New(use, Opts{Name: "emails", Workers: 1, TaskTimeout: time.Millisecond})
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Submit(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}))
+	assert.NoError(t, p.Shutdown(context.Background()))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(use.Errors.WithLabelValues(ReasonFailed)))
+}
+
+//nolint:paralleltest // replaces the DefaultRegisterer
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	prometheus.DefaultRegisterer = registry
+	defer func() { prometheus.DefaultRegisterer = prometheus.NewRegistry() }()
+
+	use := newTestUSE(t, "pool")
+
+	// Pools of a namespace share the QueueWait distribution.
+	for _, name := range []string{"emails", "reports"} {
+		p, err := New(use, Opts{Name: name, Workers: 1})
+		assert.NoError(t, err)
+		assert.NoError(t, p.Register())
+
+		assert.NoError(t, p.Submit(context.Background(), func(context.Context) error { return nil }))
+		assert.NoError(t, p.Shutdown(context.Background()))
+	}
+
+	count, err := testutil.GatherAndCount(registry, "jobs_worker_pool_queue_wait_seconds_hist")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}